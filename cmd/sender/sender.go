@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"flag"
+	"net/http"
+	"time"
 
-	"github.com/Shopify/sarama"
-	"github.com/cloudevents/sdk-go/protocol/kafka_sarama/v2"
-	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/encryption"
 	"github.com/qiujian16/events-informer/pkg/senders"
+	"github.com/qiujian16/events-informer/pkg/signing"
+	"github.com/qiujian16/events-informer/pkg/transport"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
@@ -16,52 +18,140 @@ import (
 func main() {
 	var kubeConfig string
 	var kafkaEndpoint string
+	var transportConfigFile string
+	var clusterName string
+	var listChunkSize int64
+	var bookmarkInterval time.Duration
+	var watchCache bool
+	var authorizationRules string
+	var identityMappings string
+	var signingConfig string
+	var encryptionConfig string
+	var transformRules string
+	var metricsAddress string
 
 	ctx := context.TODO()
 
 	flag.StringVar(&kubeConfig, "kubeconfig", "",
 		"Paths to a kubeconfig connect to hub.")
 	flag.StringVar(&kafkaEndpoint, "kafka-endpoint", "",
-		"Kafka endpoint.")
+		"Kafka endpoint, it is used when the transport config is not set.")
+	flag.StringVar(&transportConfigFile, "transport-config", "",
+		"Path to the transport config file.")
+	flag.StringVar(&clusterName, "cluster-name", "",
+		"Name of the cluster, only the requests addressed to the cluster are answered.")
+	flag.BoolVar(&watchCache, "watch-cache", false,
+		"Serve the lists and watches from a watch cache shared by all the watchers of a resource.")
+	flag.StringVar(&authorizationRules, "authorization-rules", "",
+		"Path to the authorization rules file, all the requests are answered if it is not set.")
+	flag.StringVar(&identityMappings, "identity-mappings", "",
+		"Path to the identity mappings file, the requesters are impersonated as the mapped identities if it is set.")
+	flag.StringVar(&signingConfig, "signing-config", "",
+		"Path to the signing config file, the responses are signed and the requests are verified as configured.")
+	flag.StringVar(&encryptionConfig, "encryption-config", "",
+		"Path to the encryption config file, the responses of the configured resources are encrypted to the requesters.")
+	flag.StringVar(&transformRules, "transform-rules", "",
+		"Path to the transform rules file, the objects are pruned and redacted as configured before they are sent.")
+	flag.StringVar(&metricsAddress, "metrics-bind-address", "",
+		"Address serving the metrics at /debug/vars, the metrics are not served if it is not set.")
+	flag.DurationVar(&bookmarkInterval, "bookmark-interval", senders.DefaultBookmarkInterval,
+		"Interval of the bookmarks sent on a quiet watch, 0 disables them.")
+	flag.Int64Var(&listChunkSize, "list-chunk-size", senders.DefaultListChunkSize,
+		"Max number of objects in one list response event.")
 	flag.Parse()
 
-	saramaConfig := sarama.NewConfig()
-	saramaConfig.Version = sarama.V2_0_0_0
+	transportConfig := transport.NewKafkaConfig(kafkaEndpoint)
+	if len(transportConfigFile) > 0 {
+		var err error
+		transportConfig, err = transport.LoadConfig(transportConfigFile)
+		if err != nil {
+			klog.Fatalf("failed to load transport config, %v", err)
+		}
+	}
 
-	sender, err := kafka_sarama.NewSender([]string{kafkaEndpoint}, saramaConfig, "response-topic")
+	clients, err := transport.NewSenderClients(transportConfig, clusterName)
 	if err != nil {
-		klog.Fatalf("failed to create protocol: %s", err.Error())
+		klog.Fatalf("failed to create clients, %v", err)
 	}
-	defer sender.Close(ctx)
+	defer clients.Close(ctx)
 
-	receiver, err := kafka_sarama.NewConsumer([]string{kafkaEndpoint}, saramaConfig, "request-group-id", "request-topic")
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
 	if err != nil {
-		klog.Fatalf("failed to create protocol: %s", err.Error())
+		klog.Fatalf("failed to build config, %v", err)
 	}
-	defer receiver.Close(ctx)
 
-	sc, err := cloudevents.NewClient(sender, cloudevents.WithTimeNow(), cloudevents.WithUUIDs())
-	if err != nil {
-		klog.Fatalf("failed to create client, %v", err)
+	dynamicClient := dynamic.NewForConfigOrDie(restConfig)
+
+	s := senders.NewDynamicSender(dynamicClient)
+	if watchCache {
+		s = senders.NewCachedSender(ctx, dynamicClient)
 	}
 
-	rc, err := cloudevents.NewClient(receiver)
-	if err != nil {
-		klog.Fatalf("failed to create client, %v", err)
+	options := []senders.SenderTransportOption{
+		senders.WithListChunkSize(listChunkSize),
+		senders.WithClusterName(clusterName),
+		senders.WithBookmarkInterval(bookmarkInterval),
+	}
+	if len(authorizationRules) > 0 {
+		authorizer, err := senders.LoadRuleAuthorizer(authorizationRules)
+		if err != nil {
+			klog.Fatalf("failed to load authorization rules, %v", err)
+		}
+		options = append(options, senders.WithAuthorizer(authorizer))
+	}
+	if len(identityMappings) > 0 {
+		mappings, err := senders.LoadIdentityMappings(identityMappings)
+		if err != nil {
+			klog.Fatalf("failed to load identity mappings, %v", err)
+		}
+		impersonator, err := senders.NewImpersonator(restConfig, mappings)
+		if err != nil {
+			klog.Fatalf("failed to create impersonator, %v", err)
+		}
+		options = append(options, senders.WithImpersonator(impersonator))
+	}
+	if len(signingConfig) > 0 {
+		signer, verifier, err := signing.Load(signingConfig)
+		if err != nil {
+			klog.Fatalf("failed to load signing config, %v", err)
+		}
+		if signer != nil {
+			options = append(options, senders.WithSigner(signer))
+		}
+		if verifier != nil {
+			options = append(options, senders.WithVerifier(verifier))
+		}
 	}
 
-	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfig)
-	if err != nil {
-		klog.Fatalf("failed to build config, %v", err)
+	if len(transformRules) > 0 {
+		transformer, err := senders.LoadRuleTransformer(transformRules)
+		if err != nil {
+			klog.Fatalf("failed to load transform rules, %v", err)
+		}
+		options = append(options, senders.WithTransformer(transformer))
 	}
 
-	dynamicClient := dynamic.NewForConfigOrDie(restConfig)
+	if len(encryptionConfig) > 0 {
+		config, err := encryption.LoadConfig(encryptionConfig)
+		if err != nil {
+			klog.Fatalf("failed to load encryption config, %v", err)
+		}
+		encryptor, err := config.Encryptor()
+		if err != nil {
+			klog.Fatalf("failed to create encryptor, %v", err)
+		}
+		options = append(options, senders.WithEncryptor(encryptor))
+	}
 
-	s := senders.NewDynamicSender(dynamicClient)
+	if len(metricsAddress) > 0 {
+		go func() {
+			klog.Fatal(http.ListenAndServe(metricsAddress, nil))
+		}()
+	}
 
-	transport := senders.NewDefaultSenderTansport(s, sc, rc)
+	t := senders.NewDefaultSenderTansport(s, clients.Sender, clients.Receiver, options...)
 
-	transport.Run(ctx)
+	t.Run(ctx)
 
 	<-ctx.Done()
 }
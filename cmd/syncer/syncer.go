@@ -3,12 +3,13 @@ package main
 import (
 	"context"
 	"flag"
+	"net/http"
 	"time"
 
-	"github.com/Shopify/sarama"
-	"github.com/cloudevents/sdk-go/protocol/kafka_sarama/v2"
-	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/encryption"
 	"github.com/qiujian16/events-informer/pkg/informers"
+	"github.com/qiujian16/events-informer/pkg/signing"
+	"github.com/qiujian16/events-informer/pkg/transport"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/tools/cache"
@@ -18,37 +19,80 @@ import (
 func main() {
 	ctx := context.TODO()
 	var kafkaEndpoint string
+	var transportConfigFile string
+	var clusterName string
+	var clientID string
+	var requestTimeout time.Duration
+	var signingConfig string
+	var decryptionKey string
+	var metricsAddress string
 
 	flag.StringVar(&kafkaEndpoint, "kafka-endpoint", "",
-		"Kafka endpoint.")
+		"Kafka endpoint, it is used when the transport config is not set.")
+	flag.StringVar(&transportConfigFile, "transport-config", "",
+		"Path to the transport config file.")
+	flag.StringVar(&clusterName, "cluster-name", "",
+		"Name of the cluster which the requests are addressed to.")
+	flag.StringVar(&clientID, "client-id", informers.NewClientID(),
+		"Unique identity of the syncer, the responses are addressed to it.")
+	flag.DurationVar(&requestTimeout, "request-timeout", informers.DefaultRequestTimeout,
+		"Timeout of waiting for the response of a list request.")
+	flag.StringVar(&signingConfig, "signing-config", "",
+		"Path to the signing config file, the requests are signed and the responses are verified as configured.")
+	flag.StringVar(&decryptionKey, "decryption-key", "",
+		"Path to the RSA private key decrypting the responses encrypted to the syncer.")
+	flag.StringVar(&metricsAddress, "metrics-bind-address", "",
+		"Address serving the metrics at /debug/vars, the metrics are not served if it is not set.")
 	flag.Parse()
 
-	saramaConfig := sarama.NewConfig()
-	saramaConfig.Version = sarama.V2_0_0_0
+	transportConfig := transport.NewKafkaConfig(kafkaEndpoint)
+	if len(transportConfigFile) > 0 {
+		var err error
+		transportConfig, err = transport.LoadConfig(transportConfigFile)
+		if err != nil {
+			klog.Fatalf("failed to load transport config, %v", err)
+		}
+	}
 
-	sender, err := kafka_sarama.NewSender([]string{kafkaEndpoint}, saramaConfig, "request-topic")
+	clients, err := transport.NewInformerClients(transportConfig, clientID)
 	if err != nil {
-		klog.Fatalf("failed to create protocol: %s", err.Error())
+		klog.Fatalf("failed to create clients, %v", err)
 	}
-	defer sender.Close(ctx)
+	defer clients.Close(ctx)
 
-	receiver, err := kafka_sarama.NewConsumer([]string{kafkaEndpoint}, saramaConfig, "response-group-id", "response-topic")
-	if err != nil {
-		klog.Fatalf("failed to create protocol: %s", err.Error())
+	options := []informers.EventSharedInformerOption{
+		informers.WithClientID(clientID),
+		informers.WithClusterName(clusterName),
+		informers.WithRequestTimeout(requestTimeout),
+	}
+	if len(signingConfig) > 0 {
+		signer, verifier, err := signing.Load(signingConfig)
+		if err != nil {
+			klog.Fatalf("failed to load signing config, %v", err)
+		}
+		if signer != nil {
+			options = append(options, informers.WithSigner(signer))
+		}
+		if verifier != nil {
+			options = append(options, informers.WithVerifier(verifier))
+		}
 	}
-	defer receiver.Close(ctx)
 
-	s, err := cloudevents.NewClient(sender, cloudevents.WithTimeNow(), cloudevents.WithUUIDs())
-	if err != nil {
-		klog.Fatalf("failed to create client, %v", err)
+	if len(decryptionKey) > 0 {
+		decryptor, err := encryption.LoadDecryptor(clientID, decryptionKey)
+		if err != nil {
+			klog.Fatalf("failed to load decryption key, %v", err)
+		}
+		options = append(options, informers.WithDecryptor(decryptor))
 	}
 
-	r, err := cloudevents.NewClient(receiver)
-	if err != nil {
-		klog.Fatalf("failed to create client, %v", err)
+	if len(metricsAddress) > 0 {
+		go func() {
+			klog.Fatal(http.ListenAndServe(metricsAddress, nil))
+		}()
 	}
 
-	informerFactory := informers.NewEventsSharedInformerFactory(ctx, s, r, 5*time.Minute)
+	informerFactory := informers.NewEventSharedInformerFactoryWithOptions(ctx, clients.Sender, clients.Receiver, 5*time.Minute, options...)
 
 	informer := informerFactory.ForResource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
 
@@ -4,59 +4,264 @@ import (
 	"fmt"
 	"strings"
 
+	cloudevents "github.com/cloudevents/sdk-go/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
 type RequestEvent struct {
 	Namespace string             `json:"namespace"`
 	Options   metav1.ListOptions `json:"options"`
+	// WatchID is the id of the watch request that a stopwatch request targets.
+	WatchID types.UID `json:"watchID,omitempty"`
 }
 
+// GetRequestEvent is a request to get an object by its name.
+type GetRequestEvent struct {
+	Namespace    string            `json:"namespace"`
+	Name         string            `json:"name"`
+	Options      metav1.GetOptions `json:"options"`
+	Subresources []string          `json:"subresources,omitempty"`
+}
+
+// GetResponseEvent is the response of a get request. It carries either the object or the
+// NotFound status if the object does not exist, other failures are sent as error responses.
+type GetResponseEvent struct {
+	Object *unstructured.Unstructured `json:"object,omitempty"`
+	Status *metav1.Status             `json:"status,omitempty"`
+}
+
+// WriteRequestEvent is a request to create, update, patch, apply or delete an object, or to
+// update the status of an object. Only the options of the mode of the request are set. The
+// object of an apply request is the applied configuration.
+type WriteRequestEvent struct {
+	Namespace    string                     `json:"namespace"`
+	Name         string                     `json:"name,omitempty"`
+	Object       *unstructured.Unstructured `json:"object,omitempty"`
+	PatchType    types.PatchType            `json:"patchType,omitempty"`
+	Patch        []byte                     `json:"patch,omitempty"`
+	Subresources []string                   `json:"subresources,omitempty"`
+
+	CreateOptions *metav1.CreateOptions `json:"createOptions,omitempty"`
+	UpdateOptions *metav1.UpdateOptions `json:"updateOptions,omitempty"`
+	PatchOptions  *metav1.PatchOptions  `json:"patchOptions,omitempty"`
+	ApplyOptions  *metav1.ApplyOptions  `json:"applyOptions,omitempty"`
+	DeleteOptions *metav1.DeleteOptions `json:"deleteOptions,omitempty"`
+}
+
+// ResultResponseEvent is the response of a write request. The object is the result of the
+// write, it is empty for a delete request.
+type ResultResponseEvent struct {
+	Object *unstructured.Unstructured `json:"object,omitempty"`
+}
+
+// ListResponseEvent is a chunk of a list response. A list response is split into chunks
+// with the index starting from 0, and the last chunk has EndOfList set.
 type ListResponseEvent struct {
 	Objects   *unstructured.UnstructuredList `json:"objects"`
+	Index     int                            `json:"index"`
 	EndOfList bool                           `json:"endOfList"`
 }
 
+// WatchResponseEvent is an event of a watch response. An event with the type Error carries
+// a metav1.Status as the object. The sender acknowledges the start of the watch with an empty
+// event. The sender sets EndOfWatch when the watch is closed, the watcher should then watch
+// again, the type and object of this event might be empty. The events of a watch are numbered
+// by Sequence starting from 1, the watcher handles them in the order of the sequence, since
+// the events might be delivered out of order. An event without sequence, e.g. the refusal of
+// the watch, is handled once it is received.
 type WatchResponseEvent struct {
-	Type   watch.EventType            `json:"type"`
-	Object *unstructured.Unstructured `json:"object"`
+	Type       watch.EventType            `json:"type"`
+	Object     *unstructured.Unstructured `json:"object"`
+	EndOfWatch bool                       `json:"endOfWatch,omitempty"`
+	Sequence   int64                      `json:"sequence,omitempty"`
+}
+
+// EncryptedData is the data of an encrypted event. The data is encrypted with a data key, and
+// the data key is sealed with the key of the recipient, so only the recipient can decrypt it.
+type EncryptedData struct {
+	// Key is the data key sealed with the public key of the recipient.
+	Key   []byte `json:"key"`
+	Nonce []byte `json:"nonce"`
+	// ContentType is the content type of the data before encryption.
+	ContentType string `json:"contentType"`
+	Ciphertext  []byte `json:"ciphertext"`
+}
+
+const (
+	ModeList          = "list"
+	ModeWatch         = "watch"
+	ModeStopWatch     = "stopwatch"
+	ModeListResponse  = "response.list"
+	ModeWatchResponse = "response.watch"
+	ModeErrorResponse = "response.error"
+	ModeGet           = "get"
+	ModeGetResponse   = "response.get"
+
+	ModeCreate         = "create"
+	ModeUpdate         = "update"
+	ModeUpdateStatus   = "updatestatus"
+	ModePatch          = "patch"
+	ModeApply          = "apply"
+	ModeDelete         = "delete"
+	ModeResultResponse = "response.result"
+
+	// responseModePrefix is the prefix of all the response modes.
+	responseModePrefix = "response."
+)
+
+// ExtensionRecipient is the cloud event extension of a response carrying the source of
+// the request, so the response is only handled by the requester.
+const ExtensionRecipient = "recipient"
+
+// ExtensionClusterName is the cloud event extension carrying the name of the cluster which
+// a request is addressed to, or a response is sent from.
+const ExtensionClusterName = "clustername"
+
+// ExtensionPartitionKey is the cloud event partitioning extension, the responses of a request
+// carry the id of the request as the partition key, so they are kept in order by the brokers
+// which order the events by key, e.g. kafka.
+const ExtensionPartitionKey = "partitionkey"
+
+// ExtensionSenderID is the cloud event extension carrying the instance id of the sender of a
+// response, a new id is generated every time the sender starts.
+const ExtensionSenderID = "senderid"
+
+// ExtensionSignature is the cloud event extension carrying the base64 encoded signature of a
+// signed event, ExtensionSignatureKey carries the id of the key which the event is signed with.
+const (
+	ExtensionSignature    = "signature"
+	ExtensionSignatureKey = "signaturekey"
+)
+
+// ExtensionEncryption is the cloud event extension carrying the encryption algorithm of an
+// event whose data is EncryptedData.
+const ExtensionEncryption = "encryption"
+
+// EventSenderStartedType is the type of the event a sender announces its start with, it is
+// not about any resource. The watches on the former instance of the sender are lost, so the
+// watchers of the cluster of the sender should watch again.
+const EventSenderStartedType = "response.started"
+
+// Recipient returns the source of the request which the response event is addressed to.
+func Recipient(evt cloudevents.Event) string {
+	return extension(evt, ExtensionRecipient)
+}
+
+// ClusterName returns the name of the cluster which the request event is addressed to,
+// or the response event is sent from.
+func ClusterName(evt cloudevents.Event) string {
+	return extension(evt, ExtensionClusterName)
 }
 
-func toGVRString(gvr schema.GroupVersionResource) string {
-	return fmt.Sprintf("%s.%s.%s", gvr.Version, gvr.Resource, gvr.Group)
+// SenderID returns the instance id of the sender of the response event.
+func SenderID(evt cloudevents.Event) string {
+	return extension(evt, ExtensionSenderID)
+}
+
+// Signature returns the signature of the event.
+func Signature(evt cloudevents.Event) string {
+	return extension(evt, ExtensionSignature)
+}
+
+// SignatureKey returns the id of the key which the event is signed with.
+func SignatureKey(evt cloudevents.Event) string {
+	return extension(evt, ExtensionSignatureKey)
+}
+
+// Encryption returns the encryption algorithm of the event, it is empty if the event is not
+// encrypted.
+func Encryption(evt cloudevents.Event) string {
+	return extension(evt, ExtensionEncryption)
+}
+
+func extension(evt cloudevents.Event, name string) string {
+	value, ok := evt.Extensions()[name].(string)
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// ErrorResponseEvent is the response of a request failed on the sender, e.g. the
+// list is forbidden by the RBAC of the sender.
+type ErrorResponseEvent struct {
+	Status metav1.Status `json:"status"`
+}
+
+// EventType builds the cloud event type of a mode and a resource. The type is formatted
+// as <mode>/<group>/<version>/<resource>. "/" never appears in a mode, an api group, a
+// version or a resource name, so the type can be parsed without ambiguity even when
+// the group contains dots, e.g. "list/networking.k8s.io/v1/ingresses". The group of
+// the core api is empty, e.g. "watch//v1/secrets".
+func EventType(mode string, gvr schema.GroupVersionResource) string {
+	return strings.Join([]string{mode, gvr.Group, gvr.Version, gvr.Resource}, "/")
 }
 
 func EventListType(gvr schema.GroupVersionResource) string {
-	return fmt.Sprintf("list.%s", toGVRString(gvr))
+	return EventType(ModeList, gvr)
 }
 
 func EventListResponseType(gvr schema.GroupVersionResource) string {
-	return fmt.Sprintf("response.list.%s", toGVRString(gvr))
+	return EventType(ModeListResponse, gvr)
 }
 
 func EventWatchType(gvr schema.GroupVersionResource) string {
-	return fmt.Sprintf("watch.%s", toGVRString(gvr))
+	return EventType(ModeWatch, gvr)
 }
 
 func EventStopWatchType(gvr schema.GroupVersionResource) string {
-	return fmt.Sprintf("stopwatch.%s", toGVRString(gvr))
+	return EventType(ModeStopWatch, gvr)
 }
 
 func EventWatchResponseType(gvr schema.GroupVersionResource) string {
-	return fmt.Sprintf("response.watch.%s", toGVRString(gvr))
+	return EventType(ModeWatchResponse, gvr)
 }
 
+func EventErrorResponseType(gvr schema.GroupVersionResource) string {
+	return EventType(ModeErrorResponse, gvr)
+}
+
+func EventGetResponseType(gvr schema.GroupVersionResource) string {
+	return EventType(ModeGetResponse, gvr)
+}
+
+func EventResultResponseType(gvr schema.GroupVersionResource) string {
+	return EventType(ModeResultResponse, gvr)
+}
+
+// ParseEventType parses the mode and the resource from a cloud event type built by EventType.
 func ParseEventType(t string) (string, schema.GroupVersionResource, error) {
-	eventTypeArray := strings.Split(t, ".")
+	eventTypeArray := strings.Split(t, "/")
 	if len(eventTypeArray) != 4 {
-		return "", schema.GroupVersionResource{}, fmt.Errorf("failed to parse event type")
+		return "", schema.GroupVersionResource{}, fmt.Errorf("failed to parse event type %q", t)
 	}
 
-	return eventTypeArray[0], schema.GroupVersionResource{
-		Version:  eventTypeArray[1],
-		Resource: eventTypeArray[2],
-		Group:    eventTypeArray[3]}, nil
+	mode, gvr := eventTypeArray[0], schema.GroupVersionResource{
+		Group:    eventTypeArray[1],
+		Version:  eventTypeArray[2],
+		Resource: eventTypeArray[3],
+	}
+	if len(mode) == 0 || len(gvr.Version) == 0 || len(gvr.Resource) == 0 {
+		return "", schema.GroupVersionResource{}, fmt.Errorf("failed to parse event type %q", t)
+	}
+
+	return mode, gvr, nil
+}
+
+// IsResponseMode returns true if the mode is the mode of a response event.
+func IsResponseMode(mode string) bool {
+	return strings.HasPrefix(mode, responseModePrefix)
+}
+
+// IsWriteMode returns true if the mode is the mode of a write request.
+func IsWriteMode(mode string) bool {
+	switch mode {
+	case ModeCreate, ModeUpdate, ModeUpdateStatus, ModePatch, ModeApply, ModeDelete:
+		return true
+	}
+	return false
 }
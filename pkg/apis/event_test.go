@@ -0,0 +1,68 @@
+package apis
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestEventTypeRoundTrip(t *testing.T) {
+	cases := []struct {
+		name         string
+		mode         string
+		gvr          schema.GroupVersionResource
+		expectedType string
+	}{
+		{
+			name:         "core group",
+			mode:         ModeWatch,
+			gvr:          schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+			expectedType: "watch//v1/secrets",
+		},
+		{
+			name:         "group",
+			mode:         ModeList,
+			gvr:          schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			expectedType: "list/apps/v1/deployments",
+		},
+		{
+			name:         "dotted group",
+			mode:         ModeListResponse,
+			gvr:          schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+			expectedType: "response.list/rbac.authorization.k8s.io/v1/clusterroles",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			eventType := EventType(c.mode, c.gvr)
+			if eventType != c.expectedType {
+				t.Errorf("expected type %q, got %q", c.expectedType, eventType)
+			}
+
+			mode, gvr, err := ParseEventType(eventType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mode != c.mode || gvr != c.gvr {
+				t.Errorf("expected %s %s, got %s %s", c.mode, c.gvr, mode, gvr)
+			}
+		})
+	}
+}
+
+func TestParseInvalidEventType(t *testing.T) {
+	for _, eventType := range []string{
+		"",
+		"list",
+		"list/v1/secrets",
+		"list//v1/secrets/extra",
+		"/apps/v1/deployments",
+		"list/apps//deployments",
+		"list/apps/v1/",
+	} {
+		if _, _, err := ParseEventType(eventType); err == nil {
+			t.Errorf("expected error of event type %q", eventType)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package encryption
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the configuration of the encryption on the sender.
+type Config struct {
+	// Resources are the resources whose responses are encrypted.
+	Resources []Resource `json:"resources"`
+	// RecipientKeys are the public keys of the recipients, a recipient without a key is
+	// forbidden to request the encrypted resources.
+	RecipientKeys []RecipientKey `json:"recipientKeys"`
+}
+
+// Resource is a resource in the group, "" is the core group.
+type Resource struct {
+	Group    string `json:"group"`
+	Resource string `json:"resource"`
+}
+
+// RecipientKey is the RSA public key of a recipient, i.e. the client id of an informer.
+type RecipientKey struct {
+	Recipient string `json:"recipient"`
+	// KeyFile is a PEM encoded PKIX public key.
+	KeyFile string `json:"keyFile"`
+}
+
+// LoadConfig reads the encryption configuration from a yaml or json file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse encryption config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// Encryptor builds the Encryptor of the configuration.
+func (c *Config) Encryptor() (Encryptor, error) {
+	resources := []schema.GroupResource{}
+	for _, resource := range c.Resources {
+		resources = append(resources, schema.GroupResource{Group: resource.Group, Resource: resource.Resource})
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, recipientKey := range c.RecipientKeys {
+		key, err := loadPEM(recipientKey.KeyFile, x509.ParsePKIXPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("key %s of %q is not a RSA public key", recipientKey.KeyFile, recipientKey.Recipient)
+		}
+		keys[recipientKey.Recipient] = publicKey
+	}
+	return NewEncryptor(resources, keys), nil
+}
+
+// LoadDecryptor builds the Decryptor of the recipient with the private key in the file, the key
+// file is a PEM encoded PKCS #8 or PKCS #1 RSA private key.
+func LoadDecryptor(recipient, path string) (Decryptor, error) {
+	key, err := loadPEM(path, func(der []byte) (interface{}, error) {
+		if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+			return key, nil
+		}
+		return x509.ParsePKCS8PrivateKey(der)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key %s is not a RSA private key", path)
+	}
+	return NewDecryptor(recipient, privateKey), nil
+}
+
+func loadPEM(path string, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block is found in %s", path)
+	}
+
+	key, err := parse(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %v", path, err)
+	}
+	return key, nil
+}
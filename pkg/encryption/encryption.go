@@ -0,0 +1,170 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/apis"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AlgorithmRSAOAEPAES256GCM encrypts the data with AES-256-GCM and a random data key, and seals
+// the data key with RSA-OAEP SHA-256 of the public key of the recipient.
+const AlgorithmRSAOAEPAES256GCM = "rsa-oaep-aes-256-gcm"
+
+const dataKeySize = 32
+
+// Encryptor encrypts the data of the responses of the sensitive resources to their recipients.
+type Encryptor interface {
+	// Accepts returns an error if the responses of the resource can not be encrypted to the
+	// recipient, i.e. the resource is encrypted and the recipient has no key.
+	Accepts(gvr schema.GroupVersionResource, recipient string) error
+	// Encrypt encrypts the data of the event to the recipient, the event is not changed if the
+	// resource is not encrypted.
+	Encrypt(evt *cloudevents.Event, gvr schema.GroupVersionResource, recipient string) error
+}
+
+// Decryptor decrypts the data of the events encrypted to the recipient.
+type Decryptor interface {
+	// Decrypt decrypts the data of the event, the event is not changed if it is not encrypted.
+	Decrypt(evt *cloudevents.Event) error
+}
+
+type encryptor struct {
+	resources map[schema.GroupResource]bool
+	keys      map[string]*rsa.PublicKey
+}
+
+// NewEncryptor builds an Encryptor encrypting the responses of the resources, the keys are the
+// public keys of the recipients keyed by the recipient.
+func NewEncryptor(resources []schema.GroupResource, keys map[string]*rsa.PublicKey) Encryptor {
+	e := &encryptor{
+		resources: map[schema.GroupResource]bool{},
+		keys:      keys,
+	}
+	for _, resource := range resources {
+		e.resources[resource] = true
+	}
+	return e
+}
+
+func (e *encryptor) Accepts(gvr schema.GroupVersionResource, recipient string) error {
+	if !e.resources[gvr.GroupResource()] {
+		return nil
+	}
+	if _, ok := e.keys[recipient]; !ok {
+		return fmt.Errorf("%s are encrypted, no encryption key is configured for %q", gvr.GroupResource(), recipient)
+	}
+	return nil
+}
+
+func (e *encryptor) Encrypt(evt *cloudevents.Event, gvr schema.GroupVersionResource, recipient string) error {
+	if err := e.Accepts(gvr, recipient); err != nil {
+		return err
+	}
+	if !e.resources[gvr.GroupResource()] {
+		return nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return err
+	}
+
+	sealedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, e.keys[recipient], dataKey, nil)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	encrypted := &apis.EncryptedData{
+		Key:         sealedKey,
+		Nonce:       nonce,
+		ContentType: evt.DataContentType(),
+		Ciphertext:  aead.Seal(nil, nonce, evt.Data(), additionalData(*evt, recipient)),
+	}
+
+	evt.SetExtension(apis.ExtensionEncryption, AlgorithmRSAOAEPAES256GCM)
+	return evt.SetData(cloudevents.ApplicationJSON, encrypted)
+}
+
+type decryptor struct {
+	recipient string
+	key       *rsa.PrivateKey
+}
+
+// NewDecryptor builds a Decryptor of the recipient with its private key.
+func NewDecryptor(recipient string, key *rsa.PrivateKey) Decryptor {
+	return &decryptor{recipient: recipient, key: key}
+}
+
+func (d *decryptor) Decrypt(evt *cloudevents.Event) error {
+	algorithm := apis.Encryption(*evt)
+	if len(algorithm) == 0 {
+		return nil
+	}
+	if algorithm != AlgorithmRSAOAEPAES256GCM {
+		return fmt.Errorf("unsupported encryption algorithm %q", algorithm)
+	}
+
+	encrypted := &apis.EncryptedData{}
+	if err := json.Unmarshal(evt.Data(), encrypted); err != nil {
+		return err
+	}
+
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, d.key, encrypted.Key, nil)
+	if err != nil {
+		return fmt.Errorf("failed to unseal the data key: %v", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	if len(encrypted.Nonce) != aead.NonceSize() {
+		return fmt.Errorf("invalid nonce size %d", len(encrypted.Nonce))
+	}
+
+	data, err := aead.Open(nil, encrypted.Nonce, encrypted.Ciphertext, additionalData(*evt, d.recipient))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the data: %v", err)
+	}
+
+	// the context is shared by the copies of the event, it is cloned before it is changed.
+	*evt = evt.Clone()
+	evt.SetExtension(apis.ExtensionEncryption, nil)
+	evt.DataEncoded = data
+	evt.SetDataContentType(encrypted.ContentType)
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the ciphertext to the event, so the encrypted data can not be moved to
+// another event or recipient.
+func additionalData(evt cloudevents.Event, recipient string) []byte {
+	return []byte(evt.ID() + "\n" + evt.Type() + "\n" + recipient)
+}
@@ -0,0 +1,169 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/apis"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	secretsGVR    = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	configMapsGVR = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+)
+
+func newKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newResponse(t *testing.T) cloudevents.Event {
+	evt := cloudevents.NewEvent()
+	evt.SetID("1")
+	evt.SetType(apis.EventGetResponseType(secretsGVR))
+	evt.SetSource("sender")
+	if err := evt.SetData(cloudevents.ApplicationJSON, map[string]string{"password": "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
+
+func TestEncryption(t *testing.T) {
+	clientKey, otherKey := newKey(t), newKey(t)
+	encryptor := NewEncryptor([]schema.GroupResource{secretsGVR.GroupResource()},
+		map[string]*rsa.PublicKey{"client": &clientKey.PublicKey, "other": &otherKey.PublicKey})
+
+	cases := []struct {
+		name      string
+		decryptor Decryptor
+		// tamper changes the encrypted event before it is decrypted.
+		tamper func(t *testing.T, evt *cloudevents.Event)
+		valid  bool
+	}{
+		{
+			name:      "round trip",
+			decryptor: NewDecryptor("client", clientKey),
+			valid:     true,
+		},
+		{
+			name:      "wrong recipient key",
+			decryptor: NewDecryptor("client", otherKey),
+		},
+		{
+			name:      "another recipient",
+			decryptor: NewDecryptor("other", otherKey),
+		},
+		{
+			name:      "tampered ciphertext",
+			decryptor: NewDecryptor("client", clientKey),
+			tamper: func(t *testing.T, evt *cloudevents.Event) {
+				encrypted := &apis.EncryptedData{}
+				if err := json.Unmarshal(evt.Data(), encrypted); err != nil {
+					t.Fatal(err)
+				}
+				encrypted.Ciphertext[0] ^= 0xff
+				if err := evt.SetData(cloudevents.ApplicationJSON, encrypted); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:      "moved to another event",
+			decryptor: NewDecryptor("client", clientKey),
+			tamper: func(t *testing.T, evt *cloudevents.Event) {
+				evt.SetID("2")
+			},
+		},
+		{
+			name:      "moved to another type",
+			decryptor: NewDecryptor("client", clientKey),
+			tamper: func(t *testing.T, evt *cloudevents.Event) {
+				evt.SetType(apis.EventGetResponseType(configMapsGVR))
+			},
+		},
+		{
+			name:      "unsupported algorithm",
+			decryptor: NewDecryptor("client", clientKey),
+			tamper: func(t *testing.T, evt *cloudevents.Event) {
+				evt.SetExtension(apis.ExtensionEncryption, "rsa-pkcs1-aes-128-cbc")
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			evt := newResponse(t)
+			data := string(evt.Data())
+			if err := encryptor.Encrypt(&evt, secretsGVR, "client"); err != nil {
+				t.Fatal(err)
+			}
+			if apis.Encryption(evt) != AlgorithmRSAOAEPAES256GCM || string(evt.Data()) == data {
+				t.Fatalf("expected the data is encrypted")
+			}
+
+			if c.tamper != nil {
+				c.tamper(t, &evt)
+			}
+
+			err := c.decryptor.Decrypt(&evt)
+			if !c.valid {
+				if err == nil {
+					t.Errorf("expected the event fails to decrypt")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(evt.Data()) != data || evt.DataContentType() != cloudevents.ApplicationJSON {
+				t.Errorf("expected data %s of %s, got %s of %s", data, cloudevents.ApplicationJSON, evt.Data(), evt.DataContentType())
+			}
+			if len(apis.Encryption(evt)) != 0 {
+				t.Errorf("expected the encryption extension is removed")
+			}
+		})
+	}
+}
+
+func TestEncryptorAccepts(t *testing.T) {
+	clientKey := newKey(t)
+	encryptor := NewEncryptor([]schema.GroupResource{secretsGVR.GroupResource()},
+		map[string]*rsa.PublicKey{"client": &clientKey.PublicKey})
+
+	if err := encryptor.Accepts(secretsGVR, "client"); err != nil {
+		t.Errorf("expected the secrets are accepted for the recipient with a key, got %v", err)
+	}
+	if err := encryptor.Accepts(secretsGVR, "stranger"); err == nil {
+		t.Errorf("expected the secrets are not accepted for the recipient without a key")
+	}
+	if err := encryptor.Accepts(configMapsGVR, "stranger"); err != nil {
+		t.Errorf("expected the resources not encrypted are accepted for any recipient, got %v", err)
+	}
+
+	evt := newResponse(t)
+	if err := encryptor.Encrypt(&evt, secretsGVR, "stranger"); err == nil {
+		t.Errorf("expected the secrets are not encrypted to the recipient without a key")
+	}
+
+	evt.SetType(apis.EventGetResponseType(configMapsGVR))
+	data := string(evt.Data())
+	if err := encryptor.Encrypt(&evt, configMapsGVR, "stranger"); err != nil {
+		t.Fatal(err)
+	}
+	if string(evt.Data()) != data || len(apis.Encryption(evt)) != 0 {
+		t.Errorf("expected the resources not encrypted are sent in plain")
+	}
+
+	// the events not encrypted are passed through by the decryptor.
+	if err := NewDecryptor("client", clientKey).Decrypt(&evt); err != nil || string(evt.Data()) != data {
+		t.Errorf("expected the plain event is not changed, got %s, %v", evt.Data(), err)
+	}
+}
@@ -0,0 +1,229 @@
+package informers
+
+import (
+	"context"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/apis"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+type eventDynamicClient struct {
+	ctx                context.Context
+	source             string
+	sender             cloudevents.Client
+	receiver           *EventReceiver
+	listWatcherOptions []ListWatcherOption
+	// clusterName is the cluster which the requests are addressed to if it is set by the options.
+	clusterName string
+}
+
+// NewEventDynamicClient builds a dynamic.Interface sending the requests as cloud events to the
+// sender, the resources of the client are EventResourceInterface. It accepts the options of the
+// EventSharedInformerFactory, e.g. WithClientID and WithClusterName, the namespace and the
+// tweak list options are ignored. The responses are received until the context is done.
+func NewEventDynamicClient(ctx context.Context, sender, receiver cloudevents.Client, options ...EventSharedInformerOption) dynamic.Interface {
+	factory := NewEventSharedInformerFactoryWithOptions(ctx, sender, receiver, 0, options...).(*eventSharedInformerFactory)
+	factory.receiver.Start(ctx)
+
+	configured := &EventListWatcher{}
+	for _, opt := range factory.listWatcherOptions {
+		opt(configured)
+	}
+
+	return &eventDynamicClient{
+		ctx:                ctx,
+		source:             factory.clientID,
+		sender:             sender,
+		receiver:           factory.receiver,
+		listWatcherOptions: factory.listWatcherOptions,
+		clusterName:        configured.clusterName,
+	}
+}
+
+func (c *eventDynamicClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &eventResourceClient{client: c, gvr: gvr}
+}
+
+// listWatcher builds a list watcher sending the requests of the resource in the namespace to
+// the cluster. The list watchers are not kept by the client, a list watcher is built for each
+// call and shut down by the returned func once the call is done.
+func (c *eventDynamicClient) listWatcher(gvr schema.GroupVersionResource, namespace, cluster string) (*EventListWatcher, context.CancelFunc) {
+	opts := c.listWatcherOptions
+	if cluster != c.clusterName {
+		opts = append(append([]ListWatcherOption{}, opts...), ClusterName(cluster))
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	return NewEventListWatcher(ctx, c.source, namespace, c.sender, c.receiver, gvr, opts...), cancel
+}
+
+type eventResourceClient struct {
+	client    *eventDynamicClient
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+var _ EventResourceInterface = &eventResourceClient{}
+
+func (r *eventResourceClient) Namespace(namespace string) dynamic.ResourceInterface {
+	return &eventResourceClient{client: r.client, gvr: r.gvr, namespace: namespace}
+}
+
+// write sends the write request. An object of the multi-cluster informers is written as it is
+// in its cluster, see ClusterObject, and the request is sent to the cluster of the object. The
+// namespace of the client may be the namespace of the object in the multi-cluster informers,
+// i.e. prefixed with the cluster. The write fails if the client is set with another cluster.
+func (r *eventResourceClient) write(ctx context.Context, mode string, request *apis.WriteRequestEvent) (*unstructured.Unstructured, error) {
+	namespace, cluster := r.namespace, r.client.clusterName
+	if request.Object != nil {
+		objCluster, obj := ClusterObject(request.Object)
+		request.Object = obj
+		if len(objCluster) > 0 {
+			if len(cluster) > 0 && objCluster != cluster {
+				return nil, apierrors.NewBadRequest(fmt.Sprintf("%s %s of cluster %s can not be written to cluster %s",
+					r.gvr.Resource, obj.GetName(), objCluster, cluster))
+			}
+
+			cluster = objCluster
+			if namespaceCluster, clusterNamespace := SplitClusterNamespace(namespace); namespaceCluster == cluster {
+				namespace = clusterNamespace
+			}
+		}
+	}
+
+	lw, done := r.client.listWatcher(r.gvr, namespace, cluster)
+	defer done()
+	return lw.write(ctx, mode, request)
+}
+
+func (r *eventResourceClient) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.write(ctx, apis.ModeCreate, &apis.WriteRequestEvent{
+		Name:          obj.GetName(),
+		Object:        obj,
+		Subresources:  subresources,
+		CreateOptions: &options,
+	})
+}
+
+func (r *eventResourceClient) Update(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.write(ctx, apis.ModeUpdate, &apis.WriteRequestEvent{
+		Name:          obj.GetName(),
+		Object:        obj,
+		Subresources:  subresources,
+		UpdateOptions: &options,
+	})
+}
+
+// UpdateStatus writes the status of the object back to the sender. The object must carry the
+// resource version it is received with, the update fails with a conflict if the object is
+// changed since.
+func (r *eventResourceClient) UpdateStatus(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions) (*unstructured.Unstructured, error) {
+	return r.write(ctx, apis.ModeUpdateStatus, &apis.WriteRequestEvent{
+		Name:          obj.GetName(),
+		Object:        obj,
+		UpdateOptions: &options,
+	})
+}
+
+func (r *eventResourceClient) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.write(ctx, apis.ModePatch, &apis.WriteRequestEvent{
+		Name:         name,
+		PatchType:    pt,
+		Patch:        data,
+		Subresources: subresources,
+		PatchOptions: &options,
+	})
+}
+
+func (r *eventResourceClient) Apply(ctx context.Context, name string, obj *unstructured.Unstructured, options metav1.ApplyOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.write(ctx, apis.ModeApply, &apis.WriteRequestEvent{
+		Name:         name,
+		Object:       obj,
+		Subresources: subresources,
+		ApplyOptions: &options,
+	})
+}
+
+func (r *eventResourceClient) ApplyStatus(ctx context.Context, name string, obj *unstructured.Unstructured, options metav1.ApplyOptions) (*unstructured.Unstructured, error) {
+	return r.Apply(ctx, name, obj, options, "status")
+}
+
+func (r *eventResourceClient) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	_, err := r.write(ctx, apis.ModeDelete, &apis.WriteRequestEvent{
+		Name:          name,
+		Subresources:  subresources,
+		DeleteOptions: &options,
+	})
+	return err
+}
+
+// DeleteCollection lists the objects and deletes them one by one, the objects deleted
+// by others in the meantime are ignored.
+func (r *eventResourceClient) DeleteCollection(ctx context.Context, options metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	objectList, err := r.List(ctx, listOptions)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, obj := range objectList.Items {
+		if err := r.Delete(ctx, obj.GetName(), options); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (r *eventResourceClient) Get(ctx context.Context, name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	lw, done := r.client.listWatcher(r.gvr, r.namespace, r.client.clusterName)
+	defer done()
+	return lw.get(ctx, name, options, subresources...)
+}
+
+func (r *eventResourceClient) List(ctx context.Context, options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	lw, done := r.client.listWatcher(r.gvr, r.namespace, r.client.clusterName)
+	defer done()
+
+	obj, err := lw.list(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	objectList, ok := obj.(*unstructured.UnstructuredList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected list %T of %s", obj, r.gvr)
+	}
+	return objectList, nil
+}
+
+// Watch watches the resource until the watch is stopped, the context only bounds sending
+// the watch request.
+func (r *eventResourceClient) Watch(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+	lw, done := r.client.listWatcher(r.gvr, r.namespace, r.client.clusterName)
+	w, err := lw.watch(ctx, options)
+	if err != nil {
+		done()
+		return nil, err
+	}
+	return &clientWatcher{Interface: w, done: done}, nil
+}
+
+// clientWatcher shuts down the list watcher of the watch when the watch is stopped.
+type clientWatcher struct {
+	watch.Interface
+	done context.CancelFunc
+}
+
+func (w *clientWatcher) Stop() {
+	w.Interface.Stop()
+	w.done()
+}
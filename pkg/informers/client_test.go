@@ -0,0 +1,131 @@
+package informers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/qiujian16/events-informer/pkg/informers"
+	eitesting "github.com/qiujian16/events-informer/pkg/testing"
+	"github.com/qiujian16/events-informer/pkg/transport"
+	"github.com/qiujian16/events-informer/pkg/transport/loopback"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// TestDynamicClient reads and writes the secrets of a fake client over the loopback bus with
+// the event dynamic client.
+func TestDynamicClient(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := eitesting.NewFakeClient(eitesting.NewSecret("ns", "a"), eitesting.NewSecret("other", "c"))
+	bus := loopback.NewBus(loopback.Options{})
+	if err := eitesting.StartLoopbackSender(ctx, bus, client); err != nil {
+		t.Fatal(err)
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := informers.NewEventDynamicClient(ctx, sender, receiver).Resource(eitesting.SecretsGVR).Namespace("ns")
+	stored := client.Resource(eitesting.SecretsGVR).Namespace("ns")
+
+	// get
+	secret, err := secrets.Get(ctx, "a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.GetName() != "a" || secret.GetNamespace() != "ns" {
+		t.Errorf("expected secret ns/a, got %s/%s", secret.GetNamespace(), secret.GetName())
+	}
+	if _, err := secrets.Get(ctx, "missing", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	// create
+	created, err := secrets.Create(ctx, eitesting.NewSecret("ns", "b"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetName() != "b" {
+		t.Errorf("expected the created secret b, got %s", created.GetName())
+	}
+	if _, err := secrets.Create(ctx, eitesting.NewSecret("ns", "b"), metav1.CreateOptions{}); !apierrors.IsAlreadyExists(err) {
+		t.Errorf("expected already exists error, got %v", err)
+	}
+
+	// update
+	secret.SetLabels(map[string]string{"updated": "true"})
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectLabel(ctx, t, stored, "a", "updated", "true")
+
+	// update status, the fake client does not set the resource version required by UpdateStatus.
+	secret.SetResourceVersion("1")
+	if err := unstructured.SetNestedField(secret.Object, "ready", "status", "phase"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := secrets.UpdateStatus(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := stored.Get(ctx, "a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phase, _, _ := unstructured.NestedString(updated.Object, "status", "phase"); phase != "ready" {
+		t.Errorf("expected the status phase ready, got %q", phase)
+	}
+
+	// patch
+	patch := []byte(`{"metadata":{"labels":{"patched":"true"}}}`)
+	if _, err := secrets.Patch(ctx, "b", types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectLabel(ctx, t, stored, "b", "patched", "true")
+
+	// delete
+	if err := secrets.Delete(ctx, "b", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stored.Get(ctx, "b", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected secret b is deleted, got %v", err)
+	}
+	if err := secrets.Delete(ctx, "b", metav1.DeleteOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	// delete collection only deletes the secrets in the namespace.
+	if _, err := secrets.Create(ctx, eitesting.NewSecret("ns", "d"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := secrets.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 0 {
+		t.Errorf("expected no secret in ns, got %d", len(list.Items))
+	}
+	if _, err := client.Resource(eitesting.SecretsGVR).Namespace("other").Get(ctx, "c", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the secret in the other namespace is kept, got %v", err)
+	}
+}
+
+func expectLabel(ctx context.Context, t *testing.T, secrets dynamic.ResourceInterface, name, key, value string) {
+	t.Helper()
+
+	obj, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := obj.GetLabels(); labels[key] != value {
+		t.Errorf("expected label %s=%s of secret %s, got %v", key, value, name, labels)
+	}
+}
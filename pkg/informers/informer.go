@@ -2,14 +2,18 @@ package informers
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/encryption"
+	"github.com/qiujian16/events-informer/pkg/signing"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/dynamic/dynamiclister"
@@ -25,9 +29,10 @@ type eventInformer struct {
 type eventSharedInformerFactory struct {
 	ctx           context.Context
 	sender        cloudevents.Client
-	receiver      cloudevents.Client
+	receiver      *EventReceiver
 	defaultResync time.Duration
 	namespace     string
+	clientID      string
 
 	lock      sync.Mutex
 	informers map[schema.GroupVersionResource]informers.GenericInformer
@@ -35,25 +40,114 @@ type eventSharedInformerFactory struct {
 	// This allows Start() to be called multiple times safely.
 	startedInformers map[schema.GroupVersionResource]bool
 	tweakListOptions dynamicinformer.TweakListOptionsFunc
+	// listWatcherOptions are applied to the list watchers of all the informers.
+	listWatcherOptions []ListWatcherOption
+}
+
+// EventSharedInformerOption defines the functional option type for EventSharedInformerFactory.
+type EventSharedInformerOption func(*eventSharedInformerFactory) *eventSharedInformerFactory
+
+// WithNamespace limits the EventSharedInformerFactory to the specified namespace.
+func WithNamespace(namespace string) EventSharedInformerOption {
+	return func(factory *eventSharedInformerFactory) *eventSharedInformerFactory {
+		factory.namespace = namespace
+		return factory
+	}
+}
+
+// WithTweakListOptions sets a custom filter on all listers of the configured EventSharedInformerFactory.
+func WithTweakListOptions(tweakListOptions dynamicinformer.TweakListOptionsFunc) EventSharedInformerOption {
+	return func(factory *eventSharedInformerFactory) *eventSharedInformerFactory {
+		factory.tweakListOptions = tweakListOptions
+		return factory
+	}
+}
+
+// WithClientID sets the identity of the factory, the requests are sent with the client id as
+// the source and only the responses addressed to the client id are handled. A unique client
+// id is generated if it is not set.
+func WithClientID(clientID string) EventSharedInformerOption {
+	return func(factory *eventSharedInformerFactory) *eventSharedInformerFactory {
+		factory.clientID = clientID
+		return factory
+	}
+}
+
+// NewClientID generates a unique client id.
+func NewClientID() string {
+	return fmt.Sprintf("events-informer-%s", uuid.NewUUID())
+}
+
+// WithClusterName targets the informers of the factory to the sender of the cluster.
+func WithClusterName(clusterName string) EventSharedInformerOption {
+	return func(factory *eventSharedInformerFactory) *eventSharedInformerFactory {
+		factory.listWatcherOptions = append(factory.listWatcherOptions, ClusterName(clusterName))
+		return factory
+	}
+}
+
+// WithRequestTimeout sets the timeout of waiting for the response of the list requests.
+func WithRequestTimeout(timeout time.Duration) EventSharedInformerOption {
+	return func(factory *eventSharedInformerFactory) *eventSharedInformerFactory {
+		factory.listWatcherOptions = append(factory.listWatcherOptions, RequestTimeout(timeout))
+		return factory
+	}
+}
+
+// WithSigner signs the requests of the informers with the signer.
+func WithSigner(signer signing.Signer) EventSharedInformerOption {
+	return func(factory *eventSharedInformerFactory) *eventSharedInformerFactory {
+		factory.listWatcherOptions = append(factory.listWatcherOptions, Signer(signer))
+		return factory
+	}
+}
+
+// WithVerifier verifies the responses of the informers with the verifier, the responses
+// without a valid signature are dropped.
+func WithVerifier(verifier signing.Verifier) EventSharedInformerOption {
+	return func(factory *eventSharedInformerFactory) *eventSharedInformerFactory {
+		factory.listWatcherOptions = append(factory.listWatcherOptions, Verifier(verifier))
+		return factory
+	}
+}
+
+// WithDecryptor decrypts the responses encrypted to the informers with the decryptor.
+func WithDecryptor(decryptor encryption.Decryptor) EventSharedInformerOption {
+	return func(factory *eventSharedInformerFactory) *eventSharedInformerFactory {
+		factory.listWatcherOptions = append(factory.listWatcherOptions, Decryptor(decryptor))
+		return factory
+	}
 }
 
 func NewEventsSharedInformerFactory(ctx context.Context, sender, receiver cloudevents.Client, defaultResync time.Duration) EventSharedInformerFactory {
-	return NewFilteredEventSharedInformerFactory(ctx, sender, receiver, defaultResync, metav1.NamespaceAll, nil)
+	return NewEventSharedInformerFactoryWithOptions(ctx, sender, receiver, defaultResync)
 }
 
 // NewFilteredDynamicSharedInformerFactory constructs a new instance of dynamicSharedInformerFactory.
 // Listers obtained via this factory will be subject to the same filters as specified here.
 func NewFilteredEventSharedInformerFactory(ctx context.Context, sender, receiver cloudevents.Client, defaultResync time.Duration, namespace string, tweakListOptions dynamicinformer.TweakListOptionsFunc) EventSharedInformerFactory {
-	return &eventSharedInformerFactory{
+	return NewEventSharedInformerFactoryWithOptions(ctx, sender, receiver, defaultResync, WithNamespace(namespace), WithTweakListOptions(tweakListOptions))
+}
+
+// NewEventSharedInformerFactoryWithOptions constructs a new instance of a EventSharedInformerFactory with additional options.
+func NewEventSharedInformerFactoryWithOptions(ctx context.Context, sender, receiver cloudevents.Client, defaultResync time.Duration, options ...EventSharedInformerOption) EventSharedInformerFactory {
+	factory := &eventSharedInformerFactory{
 		ctx:              ctx,
 		sender:           sender,
-		receiver:         receiver,
+		receiver:         sharedEventReceiver(ctx, receiver),
 		defaultResync:    defaultResync,
-		namespace:        namespace,
+		namespace:        metav1.NamespaceAll,
+		clientID:         NewClientID(),
 		informers:        map[schema.GroupVersionResource]informers.GenericInformer{},
 		startedInformers: make(map[schema.GroupVersionResource]bool),
-		tweakListOptions: tweakListOptions,
 	}
+
+	// Apply all options
+	for _, opt := range options {
+		factory = opt(factory)
+	}
+
+	return factory
 }
 
 var _ EventSharedInformerFactory = &eventSharedInformerFactory{}
@@ -68,7 +162,7 @@ func (f *eventSharedInformerFactory) ForResource(gvr schema.GroupVersionResource
 		return informer
 	}
 
-	informer = NewFilteredEventsInformer(f.ctx, f.sender, f.receiver, gvr, f.namespace, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+	informer = NewFilteredEventsInformer(f.ctx, f.clientID, f.sender, f.receiver, gvr, f.namespace, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions, f.listWatcherOptions...)
 	f.informers[key] = informer
 
 	return informer
@@ -79,6 +173,8 @@ func (f *eventSharedInformerFactory) Start() {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
+	f.receiver.Start(f.ctx)
+
 	for informerType, informer := range f.informers {
 		if !f.startedInformers[informerType] {
 			go informer.Informer().Run(f.ctx.Done())
@@ -111,14 +207,26 @@ func (f *eventSharedInformerFactory) WaitForCacheSync(stopCh <-chan struct{}) ma
 
 func NewFilteredEventsInformer(
 	ctx context.Context,
-	sender, receiver cloudevents.Client,
+	source string,
+	sender cloudevents.Client,
+	receiver *EventReceiver,
 	gvr schema.GroupVersionResource,
 	namespace string,
 	resyncPeriod time.Duration,
 	indexers cache.Indexers,
-	tweakListOptions dynamicinformer.TweakListOptionsFunc) informers.GenericInformer {
-	lw := NewEventListWatcher(ctx, "agent", namespace, sender, receiver, gvr)
+	tweakListOptions dynamicinformer.TweakListOptionsFunc,
+	opts ...ListWatcherOption) informers.GenericInformer {
+	lw := NewEventListWatcher(ctx, source, namespace, sender, receiver, gvr, opts...)
+
+	return newEventInformer(lw, gvr, resyncPeriod, indexers, tweakListOptions)
+}
 
+func newEventInformer(
+	lw cache.ListerWatcher,
+	gvr schema.GroupVersionResource,
+	resyncPeriod time.Duration,
+	indexers cache.Indexers,
+	tweakListOptions dynamicinformer.TweakListOptionsFunc) informers.GenericInformer {
 	return &eventInformer{
 		gvr: gvr,
 		informer: cache.NewSharedIndexInformer(
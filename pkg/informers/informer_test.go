@@ -0,0 +1,90 @@
+package informers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/qiujian16/events-informer/pkg/informers"
+	eitesting "github.com/qiujian16/events-informer/pkg/testing"
+	"github.com/qiujian16/events-informer/pkg/transport"
+	"github.com/qiujian16/events-informer/pkg/transport/loopback"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// TestFactoriesShareReceiver stops one of the factories built on the same receiver client,
+// the other factory keeps receiving.
+func TestFactoriesShareReceiver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := eitesting.NewFakeClient(eitesting.NewSecret("ns", "a"))
+	bus := loopback.NewBus(loopback.Options{})
+	if err := eitesting.StartLoopbackSender(ctx, bus, client); err != nil {
+		t.Fatal(err)
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstCtx, stopFirst := context.WithCancel(ctx)
+	first := informers.NewEventsSharedInformerFactory(firstCtx, sender, receiver, 0)
+	first.ForResource(eitesting.SecretsGVR).Informer()
+	first.Start()
+	if synced := first.WaitForCacheSync(ctx.Done()); !synced[eitesting.SecretsGVR] {
+		t.Fatalf("expected the informer of %s is synced", eitesting.SecretsGVR)
+	}
+
+	second := informers.NewEventsSharedInformerFactory(ctx, sender, receiver, 0)
+	added := make(chan string, 10)
+	second.ForResource(eitesting.SecretsGVR).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			added <- obj.(*unstructured.Unstructured).GetName()
+		},
+	})
+	second.Start()
+	if synced := second.WaitForCacheSync(ctx.Done()); !synced[eitesting.SecretsGVR] {
+		t.Fatalf("expected the informer of %s is synced", eitesting.SecretsGVR)
+	}
+	expectAdded(t, added, "a")
+
+	stopFirst()
+
+	// the watch of the second factory might start after the create, create until one is added.
+	secrets := client.Resource(eitesting.SecretsGVR).Namespace("ns")
+	timeout := time.After(10 * time.Second)
+	for i := 0; ; i++ {
+		name := string(rune('b' + i))
+		if _, err := secrets.Create(ctx, eitesting.NewSecret("ns", name), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case got := <-added:
+			if got != name {
+				t.Fatalf("expected secret %q is added, got %q", name, got)
+			}
+			return
+		case <-time.After(500 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("the second factory stops receiving after the first one is stopped")
+		}
+	}
+}
+
+func expectAdded(t *testing.T, added <-chan string, expected string) {
+	t.Helper()
+
+	select {
+	case name := <-added:
+		if name != expected {
+			t.Errorf("expected secret %q is added, got %q", expected, name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for secret %q", expected)
+	}
+}
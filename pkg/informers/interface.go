@@ -1,7 +1,12 @@
 package informers
 
 import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 )
 
@@ -10,3 +15,19 @@ type EventSharedInformerFactory interface {
 	ForResource(gvr schema.GroupVersionResource) informers.GenericInformer
 	WaitForCacheSync(stopCh <-chan struct{}) map[schema.GroupVersionResource]bool
 }
+
+// MultiClusterInformerFactory builds the informers aggregating a resource of many clusters,
+// each informer is fed by the senders of all the clusters.
+type MultiClusterInformerFactory interface {
+	EventSharedInformerFactory
+	// Clusters returns the clusters which the informers are fed by.
+	Clusters() []string
+}
+
+// EventResourceInterface is a dynamic.ResourceInterface sending the requests as cloud events,
+// the objects can be applied with server side apply as well.
+type EventResourceInterface interface {
+	dynamic.ResourceInterface
+	Apply(ctx context.Context, name string, obj *unstructured.Unstructured, options metav1.ApplyOptions, subresources ...string) (*unstructured.Unstructured, error)
+	ApplyStatus(ctx context.Context, name string, obj *unstructured.Unstructured, options metav1.ApplyOptions) (*unstructured.Unstructured, error)
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/qiujian16/events-informer/pkg/apis"
+	"github.com/qiujian16/events-informer/pkg/encryption"
+	"github.com/qiujian16/events-informer/pkg/signing"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -21,14 +26,76 @@ import (
 
 type EventListWatcher struct {
 	sender         cloudevents.Client
-	receiver       cloudevents.Client
 	gvr            schema.GroupVersionResource
 	source         string
+	clusterName    string
 	namespace      string
 	ctx            context.Context
-	watcher        *eventWatcher
-	listResultChan map[types.UID]chan apis.ListResponseEvent
-	rwlock         sync.RWMutex
+	requestTimeout time.Duration
+	requests       *pendingRequests
+	// signer signs the requests, and verifier verifies the responses if they are set.
+	signer   signing.Signer
+	verifier signing.Verifier
+	// decryptor decrypts the encrypted responses if it is set.
+	decryptor encryption.Decryptor
+
+	// rwlock guards the active watchers keyed by the watch id
+	rwlock   sync.RWMutex
+	watchers map[types.UID]*eventWatcher
+}
+
+// DefaultRequestTimeout is the default timeout of waiting for the response of a request.
+const DefaultRequestTimeout = time.Minute
+
+// ListWatcherOption configures the EventListWatcher.
+type ListWatcherOption func(*EventListWatcher)
+
+// RequestTimeout sets the timeout of waiting for the response of a request. If no response
+// is received in time, e.g. the sender is down, the request fails with a timeout error.
+func RequestTimeout(timeout time.Duration) ListWatcherOption {
+	return func(e *EventListWatcher) {
+		e.requestTimeout = timeout
+	}
+}
+
+// ClusterName sets the cluster which the requests are addressed to, only the responses
+// from the cluster are handled.
+func ClusterName(clusterName string) ListWatcherOption {
+	return func(e *EventListWatcher) {
+		e.clusterName = clusterName
+	}
+}
+
+// Signer sets the signer of the requests, so the sender can verify that the requests are
+// sent by the list watcher.
+func Signer(signer signing.Signer) ListWatcherOption {
+	return func(e *EventListWatcher) {
+		e.signer = signer
+	}
+}
+
+// Verifier sets the verifier of the responses, the responses without a valid signature are
+// dropped, so a rogue producer can not inject the responses.
+func Verifier(verifier signing.Verifier) ListWatcherOption {
+	return func(e *EventListWatcher) {
+		e.verifier = verifier
+	}
+}
+
+// Decryptor sets the decryptor of the responses encrypted to the list watcher.
+func Decryptor(decryptor encryption.Decryptor) ListWatcherOption {
+	return func(e *EventListWatcher) {
+		e.decryptor = decryptor
+	}
+}
+
+// requestResult is a chunk of the list response, the object of the get request, the result
+// of the write request or the error of the request.
+type requestResult struct {
+	list   *apis.ListResponseEvent
+	get    *apis.GetResponseEvent
+	result *apis.ResultResponseEvent
+	err    error
 }
 
 type Event interface {
@@ -36,12 +103,14 @@ type Event interface {
 }
 
 type ListWatchEvent struct {
-	uid       types.UID
-	gvr       schema.GroupVersionResource
-	options   metav1.ListOptions
-	mode      string
-	source    string
-	namespace string
+	uid         types.UID
+	gvr         schema.GroupVersionResource
+	options     metav1.ListOptions
+	mode        string
+	source      string
+	namespace   string
+	watchID     types.UID
+	clusterName string
 }
 
 func newListWatchEvent(source, mode, namespace string, gvr schema.GroupVersionResource, options metav1.ListOptions) *ListWatchEvent {
@@ -61,59 +130,297 @@ func (l *ListWatchEvent) ToCloudEvent() cloudevents.Event {
 	data := &apis.RequestEvent{
 		Namespace: l.namespace,
 		Options:   l.options,
+		WatchID:   l.watchID,
 	}
 
 	evt.SetType(l.mode)
 	evt.SetID(string(l.uid))
 	evt.SetSource(l.source)
+	if len(l.clusterName) > 0 {
+		evt.SetExtension(apis.ExtensionClusterName, l.clusterName)
+	}
 	evt.SetData(cloudevents.ApplicationJSON, data)
 	return evt
 }
 
-func NewEventListWatcher(ctx context.Context, source, namespace string, sender, receiver cloudevents.Client, gvr schema.GroupVersionResource) *EventListWatcher {
+// ObjectEvent is a request of an object, e.g. to get, create, update, patch, apply or delete
+// an object.
+type ObjectEvent struct {
+	uid         types.UID
+	gvr         schema.GroupVersionResource
+	mode        string
+	source      string
+	clusterName string
+	data        interface{}
+}
+
+func (o *ObjectEvent) ToCloudEvent() cloudevents.Event {
+	evt := cloudevents.NewEvent()
+
+	evt.SetType(apis.EventType(o.mode, o.gvr))
+	evt.SetID(string(o.uid))
+	evt.SetSource(o.source)
+	if len(o.clusterName) > 0 {
+		evt.SetExtension(apis.ExtensionClusterName, o.clusterName)
+	}
+	evt.SetData(cloudevents.ApplicationJSON, o.data)
+	return evt
+}
+
+func NewEventListWatcher(ctx context.Context, source, namespace string, sender cloudevents.Client, receiver *EventReceiver, gvr schema.GroupVersionResource, opts ...ListWatcherOption) *EventListWatcher {
 	lw := &EventListWatcher{
 		source:         source,
 		sender:         sender,
-		receiver:       receiver,
 		gvr:            gvr,
 		ctx:            ctx,
 		namespace:      namespace,
-		listResultChan: map[types.UID]chan apis.ListResponseEvent{},
+		requestTimeout: DefaultRequestTimeout,
+		requests:       newPendingRequests(),
+		watchers:       map[types.UID]*eventWatcher{},
 	}
 
-	// start list/watch receiver
-	go receiver.StartReceiver(ctx, func(evt cloudevents.Event) error {
-		lw.rwlock.RLock()
-		defer lw.rwlock.RUnlock()
+	for _, opt := range opts {
+		opt(lw)
+	}
 
-		klog.Infof("received response event %s, %v", evt.Type(), evt)
-		switch evt.Type() {
-		case apis.EventListResponseType(lw.gvr):
-			resulctChan, ok := lw.listResultChan[types.UID(evt.ID())]
+	receiver.register(lw)
 
-			if !ok {
-				return fmt.Errorf("unable to find the related uid for list %s", evt.ID())
-			}
+	// stop dispatching the responses to the list watcher when it is shut down.
+	go func() {
+		<-ctx.Done()
+		receiver.unregister(lw)
+		for _, watcher := range lw.removeAllWatchers() {
+			watcher.end()
+		}
+	}()
 
-			response := &apis.ListResponseEvent{}
+	return lw
+}
 
-			err := json.Unmarshal(evt.Data(), response)
-			if err != nil {
-				return err
-			}
+// process handles the response event dispatched by the EventReceiver. Responses of
+// requests that are not sent by this list watcher are ignored.
+func (e *EventListWatcher) process(mode string, evt cloudevents.Event) error {
+	if apis.Recipient(evt) != e.source || apis.ClusterName(evt) != e.clusterName {
+		return nil
+	}
 
-			resulctChan <- *response
-		case apis.EventWatchResponseType(lw.gvr):
-			if lw.watcher == nil {
-				return nil
-			}
-			return lw.watcher.process(evt)
+	if err := e.verify(evt); err != nil {
+		return err
+	}
+
+	if err := e.decrypt(&evt); err != nil {
+		return err
+	}
+
+	uid := types.UID(evt.ID())
+
+	switch mode {
+	case apis.ModeListResponse:
+		if !e.requests.has(uid) {
+			return nil
 		}
 
+		response := &apis.ListResponseEvent{}
+
+		err := json.Unmarshal(evt.Data(), response)
+		if err != nil {
+			return err
+		}
+
+		e.requests.deliver(uid, requestResult{list: response})
+	case apis.ModeGetResponse:
+		if !e.requests.has(uid) {
+			return nil
+		}
+
+		response := &apis.GetResponseEvent{}
+
+		err := json.Unmarshal(evt.Data(), response)
+		if err != nil {
+			return err
+		}
+
+		e.requests.deliver(uid, requestResult{get: response})
+	case apis.ModeResultResponse:
+		if !e.requests.has(uid) {
+			return nil
+		}
+
+		response := &apis.ResultResponseEvent{}
+
+		err := json.Unmarshal(evt.Data(), response)
+		if err != nil {
+			return err
+		}
+
+		e.requests.deliver(uid, requestResult{result: response})
+	case apis.ModeErrorResponse:
+		if !e.requests.has(uid) {
+			return nil
+		}
+
+		response := &apis.ErrorResponseEvent{}
+
+		err := json.Unmarshal(evt.Data(), response)
+		if err != nil {
+			return err
+		}
+
+		e.requests.deliver(uid, requestResult{err: apierrors.FromObject(&response.Status)})
+	case apis.ModeWatchResponse:
+		watcher := e.getWatcher(uid)
+		if watcher == nil {
+			return nil
+		}
+
+		err := watcher.process(evt)
+		// the watch is ended by the sender, stop routing responses to it.
+		if watcher.stopped() {
+			e.removeWatcher(uid)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// senderStarted ends the watchers served by a former instance of the started sender of the
+// cluster, their watches are lost with the former instance. The consumers, e.g. the
+// reflectors, watch again from their last resource versions without listing again.
+func (e *EventListWatcher) senderStarted(evt cloudevents.Event) {
+	if apis.ClusterName(evt) != e.clusterName {
+		return
+	}
+
+	if err := e.verify(evt); err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	senderID := apis.SenderID(evt)
+
+	e.rwlock.Lock()
+	watchers := []*eventWatcher{}
+	for watchID, watcher := range e.watchers {
+		if watcher.servedByOther(senderID) {
+			watchers = append(watchers, watcher)
+			delete(e.watchers, watchID)
+		}
+	}
+	e.rwlock.Unlock()
+
+	for _, watcher := range watchers {
+		klog.Infof("sender of %s is restarted, end watch %s", e.gvr, watcher.uid)
+		watcher.end()
+	}
+}
+
+// verify verifies the signature of the response if the verifier is set.
+func (e *EventListWatcher) verify(evt cloudevents.Event) error {
+	if e.verifier == nil {
+		return nil
+	}
+
+	if err := e.verifier.Verify(evt); err != nil {
+		klog.Warningf("drop response %s of %s from %s: %v", evt.ID(), e.gvr, evt.Source(), err)
+		return err
+	}
+	return nil
+}
+
+// decrypt decrypts the data of an encrypted response.
+func (e *EventListWatcher) decrypt(evt *cloudevents.Event) error {
+	if len(apis.Encryption(*evt)) == 0 {
 		return nil
+	}
+
+	if e.decryptor == nil {
+		return fmt.Errorf("response %s of %s is encrypted, no decryption key is configured", evt.ID(), e.gvr)
+	}
+
+	if err := e.decryptor.Decrypt(evt); err != nil {
+		return fmt.Errorf("failed to decrypt response %s of %s: %v", evt.ID(), e.gvr, err)
+	}
+	return nil
+}
+
+// send signs the request if the signer is set, and sends it.
+func (e *EventListWatcher) send(ctx context.Context, request Event) error {
+	evt := request.ToCloudEvent()
+	if e.signer != nil {
+		if err := e.signer.Sign(&evt); err != nil {
+			return err
+		}
+	}
+	return e.sender.Send(ctx, evt)
+}
+
+func (e *EventListWatcher) getWatcher(watchID types.UID) *eventWatcher {
+	e.rwlock.RLock()
+	defer e.rwlock.RUnlock()
+
+	return e.watchers[watchID]
+}
+
+func (e *EventListWatcher) addWatcher(watcher *eventWatcher) {
+	e.rwlock.Lock()
+	defer e.rwlock.Unlock()
+
+	e.watchers[watcher.uid] = watcher
+}
+
+func (e *EventListWatcher) removeWatcher(watchID types.UID) {
+	e.rwlock.Lock()
+	defer e.rwlock.Unlock()
+
+	delete(e.watchers, watchID)
+}
+
+// removeAllWatchers removes and returns all the active watchers.
+func (e *EventListWatcher) removeAllWatchers() []*eventWatcher {
+	e.rwlock.Lock()
+	defer e.rwlock.Unlock()
+
+	watchers := []*eventWatcher{}
+	for watchID, watcher := range e.watchers {
+		watchers = append(watchers, watcher)
+		delete(e.watchers, watchID)
+	}
+	return watchers
+}
+
+// WatchInfo describes an active watch of the EventListWatcher.
+type WatchInfo struct {
+	ID        types.UID
+	Options   metav1.ListOptions
+	StartTime time.Time
+}
+
+// ActiveWatches returns the watches which are not stopped yet, sorted by the start time.
+func (e *EventListWatcher) ActiveWatches() []WatchInfo {
+	e.rwlock.RLock()
+	defer e.rwlock.RUnlock()
+
+	watches := []WatchInfo{}
+	for _, watcher := range e.watchers {
+		watches = append(watches, WatchInfo{
+			ID:        watcher.uid,
+			Options:   watcher.options,
+			StartTime: watcher.startTime,
+		})
+	}
+
+	sort.Slice(watches, func(i, j int) bool {
+		return watches[i].StartTime.Before(watches[j].StartTime)
 	})
+	return watches
+}
 
-	return lw
+// newRequest builds a request of the list watcher addressed to its cluster.
+func (e *EventListWatcher) newRequest(eventType string, options metav1.ListOptions) *ListWatchEvent {
+	request := newListWatchEvent(e.source, eventType, e.namespace, e.gvr, options)
+	request.clusterName = e.clusterName
+	return request
 }
 
 func (e *EventListWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
@@ -125,23 +432,31 @@ func (e *EventListWatcher) Watch(options metav1.ListOptions) (watch.Interface, e
 }
 
 func (e *EventListWatcher) watch(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
-	watchEvent := newListWatchEvent(e.source, apis.EventWatchType(e.gvr), e.namespace, e.gvr, options)
+	watchEvent := e.newRequest(apis.EventWatchType(e.gvr), options)
+
+	// set the watcher before sending the request, so no watch response is missed.
+	watcher := newEventWatcher(watchEvent.uid, func() { e.stopWatch(watchEvent.uid) }, e.gvr, options, 10)
+	e.addWatcher(watcher)
 
-	result := e.sender.Send(ctx, watchEvent.ToCloudEvent())
+	result := e.send(ctx, watchEvent)
 	if cloudevents.IsUndelivered(result) {
-		return nil, fmt.Errorf("failed to send list event, %v", result)
+		e.removeWatcher(watchEvent.uid)
+		return nil, fmt.Errorf("failed to send watch event, %v", result)
 	}
 
 	klog.Infof("sent watch event with result %v", result)
 
-	e.watcher = newEventWatcher(watchEvent.uid, e.stopWatch, e.gvr, 10)
-
-	return e.watcher, nil
+	return watcher, nil
 }
 
-func (e *EventListWatcher) stopWatch() {
-	stopWatch := newListWatchEvent(e.source, apis.EventStopWatchType(e.gvr), e.namespace, e.gvr, metav1.ListOptions{})
-	result := e.sender.Send(e.ctx, stopWatch.ToCloudEvent())
+// stopWatch stops routing the responses to the watcher and requests the sender to stop
+// the watch with the watchID.
+func (e *EventListWatcher) stopWatch(watchID types.UID) {
+	e.removeWatcher(watchID)
+
+	stopWatch := e.newRequest(apis.EventStopWatchType(e.gvr), metav1.ListOptions{})
+	stopWatch.watchID = watchID
+	result := e.send(e.ctx, stopWatch)
 
 	if cloudevents.IsUndelivered(result) {
 		utilruntime.HandleError(fmt.Errorf(result.Error()))
@@ -149,37 +464,217 @@ func (e *EventListWatcher) stopWatch() {
 }
 
 func (e *EventListWatcher) list(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
-	listEvent := newListWatchEvent(e.source, apis.EventListType(e.gvr), e.namespace, e.gvr, options)
+	listEvent := e.newRequest(apis.EventListType(e.gvr), options)
 
-	result := e.sender.Send(ctx, listEvent.ToCloudEvent())
+	if e.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.requestTimeout)
+		defer cancel()
+	}
+
+	// register the result channel before sending the request, so no chunk is missed.
+	request := e.requests.add(listEvent.uid)
+	defer e.requests.remove(listEvent.uid)
+
+	result := e.send(ctx, listEvent)
 	if cloudevents.IsUndelivered(result) {
 		return nil, fmt.Errorf("failed to send list event, %v", result)
 	}
 
 	klog.Infof("sent list event with result %v", result)
 
-	objectList := &unstructured.UnstructuredList{}
-
-	// now start to recieve the list response until endofList is false
-	e.listResultChan[listEvent.uid] = make(chan apis.ListResponseEvent)
-	defer delete(e.listResultChan, listEvent.uid)
+	// now start to recieve the list response chunks until all the chunks up to the end of list are received
+	chunks := newListChunks()
 	for {
 		select {
-		case response, ok := <-e.listResultChan[listEvent.uid]:
-			if !ok {
-				return objectList, nil
+		case result := <-request.results:
+			if result.err != nil {
+				return nil, result.err
+			}
+
+			if result.list == nil {
+				return nil, fmt.Errorf("unexpected response of the list request of %s", e.gvr)
 			}
 
-			if objectList.Object == nil {
-				objectList.Object = response.Objects.Object
+			if err := chunks.add(*result.list); err != nil {
+				return nil, err
 			}
 
-			objectList.Items = append(objectList.Items, response.Objects.Items...)
-			if response.EndOfList {
-				return objectList, nil
+			if chunks.completed() {
+				return chunks.list(), nil
 			}
 		case <-ctx.Done():
-			return objectList, nil
+			if len(chunks.chunks) > 0 {
+				return nil, fmt.Errorf("list of %s is incomplete, %s", e.gvr, chunks.missing())
+			}
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, apierrors.NewTimeoutError(fmt.Sprintf("timeout waiting for the list response of %s", e.gvr), 0)
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// get sends the get request of the object in the namespace of the list watcher and waits
+// for the object.
+func (e *EventListWatcher) get(ctx context.Context, name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	result, err := e.objectRequest(ctx, apis.ModeGet, &apis.GetRequestEvent{
+		Namespace:    e.namespace,
+		Name:         name,
+		Options:      options,
+		Subresources: subresources,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.get == nil {
+		return nil, fmt.Errorf("unexpected response of the get request of %s", e.gvr)
+	}
+	if result.get.Status != nil {
+		return nil, apierrors.FromObject(result.get.Status)
+	}
+	return result.get.Object, nil
+}
+
+// write sends the write request in the namespace of the list watcher and waits for the result.
+func (e *EventListWatcher) write(ctx context.Context, mode string, request *apis.WriteRequestEvent) (*unstructured.Unstructured, error) {
+	request.Namespace = e.namespace
+	result, err := e.objectRequest(ctx, mode, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.result == nil {
+		return nil, fmt.Errorf("unexpected response of the %s request of %s", mode, e.gvr)
+	}
+	return result.result.Object, nil
+}
+
+// objectRequest sends the request of an object and waits for the response.
+func (e *EventListWatcher) objectRequest(ctx context.Context, mode string, data interface{}) (requestResult, error) {
+	objectEvent := &ObjectEvent{
+		uid:         uuid.NewUUID(),
+		gvr:         e.gvr,
+		mode:        mode,
+		source:      e.source,
+		clusterName: e.clusterName,
+		data:        data,
+	}
+
+	if e.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.requestTimeout)
+		defer cancel()
+	}
+
+	// register the result channel before sending the request, so the response is not missed.
+	pending := e.requests.add(objectEvent.uid)
+	defer e.requests.remove(objectEvent.uid)
+
+	result := e.send(ctx, objectEvent)
+	if cloudevents.IsUndelivered(result) {
+		return requestResult{}, fmt.Errorf("failed to send %s event, %v", mode, result)
+	}
+
+	select {
+	case result := <-pending.results:
+		return result, result.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return requestResult{}, apierrors.NewTimeoutError(fmt.Sprintf("timeout waiting for the response of the %s request of %s", mode, e.gvr), 0)
+		}
+		return requestResult{}, ctx.Err()
+	}
+}
+
+// listChunks assembles the chunks of a list response. The chunks might arrive out of
+// order, they are put back in order by their index.
+type listChunks struct {
+	chunks map[int]*unstructured.UnstructuredList
+	// total is the number of chunks, it is unknown until the chunk with endOfList is received.
+	total int
+}
+
+func newListChunks() *listChunks {
+	return &listChunks{
+		chunks: map[int]*unstructured.UnstructuredList{},
+		total:  -1,
+	}
+}
+
+func (c *listChunks) add(response apis.ListResponseEvent) error {
+	if response.Objects == nil {
+		return fmt.Errorf("chunk %d of list has no objects", response.Index)
+	}
+
+	if response.Index < 0 || (c.total >= 0 && response.Index >= c.total) {
+		return fmt.Errorf("chunk %d of list is out of range", response.Index)
+	}
+
+	// all the chunks are from the same snapshot, the watch starts from its resource version.
+	for index, chunk := range c.chunks {
+		if chunk.GetResourceVersion() != response.Objects.GetResourceVersion() {
+			return fmt.Errorf("resource version %q of chunk %d of list is inconsistent with %q of chunk %d",
+				response.Objects.GetResourceVersion(), response.Index, chunk.GetResourceVersion(), index)
+		}
+	}
+
+	// a chunk might be delivered more than once, ignore the duplicated one.
+	if _, ok := c.chunks[response.Index]; ok {
+		return nil
+	}
+
+	if response.EndOfList {
+		for index := range c.chunks {
+			if index > response.Index {
+				return fmt.Errorf("chunk %d of list is after the end of list", index)
+			}
+		}
+		c.total = response.Index + 1
+	}
+
+	c.chunks[response.Index] = response.Objects
+	return nil
+}
+
+func (c *listChunks) completed() bool {
+	return c.total >= 0 && len(c.chunks) == c.total
+}
+
+// missing describes the chunks which are not received yet.
+func (c *listChunks) missing() string {
+	upper := c.total
+	if upper < 0 {
+		for index := range c.chunks {
+			if index+1 > upper {
+				upper = index + 1
+			}
+		}
+	}
+
+	missing := []int{}
+	for index := 0; index < upper; index++ {
+		if _, ok := c.chunks[index]; !ok {
+			missing = append(missing, index)
+		}
+	}
+
+	if c.total < 0 {
+		return fmt.Sprintf("missing chunks %v and the end of list", missing)
+	}
+	return fmt.Sprintf("missing chunks %v", missing)
+}
+
+func (c *listChunks) list() *unstructured.UnstructuredList {
+	objectList := &unstructured.UnstructuredList{}
+	for index := 0; index < c.total; index++ {
+		chunk := c.chunks[index]
+		// the metadata of the last chunk carries the resource version and the continue token of the list.
+		if index == c.total-1 {
+			objectList.Object = chunk.Object
 		}
+		objectList.Items = append(objectList.Items, chunk.Items...)
 	}
+	return objectList
 }
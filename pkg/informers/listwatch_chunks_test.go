@@ -0,0 +1,145 @@
+package informers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qiujian16/events-informer/pkg/apis"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newListChunk(index int, endOfList bool, resourceVersion string, names ...string) apis.ListResponseEvent {
+	objects := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	objects.SetResourceVersion(resourceVersion)
+	for _, name := range names {
+		obj := unstructured.Unstructured{}
+		obj.SetName(name)
+		objects.Items = append(objects.Items, obj)
+	}
+	return apis.ListResponseEvent{Objects: objects, Index: index, EndOfList: endOfList}
+}
+
+func TestListChunks(t *testing.T) {
+	cases := []struct {
+		name          string
+		chunks        []apis.ListResponseEvent
+		expectedNames []string
+		expectedError string
+		missing       string
+	}{
+		{
+			name: "in order",
+			chunks: []apis.ListResponseEvent{
+				newListChunk(0, false, "10", "a", "b"),
+				newListChunk(1, true, "10", "c"),
+			},
+			expectedNames: []string{"a", "b", "c"},
+		},
+		{
+			name: "out of order",
+			chunks: []apis.ListResponseEvent{
+				newListChunk(2, true, "10", "e"),
+				newListChunk(0, false, "10", "a", "b"),
+				newListChunk(1, false, "10", "c", "d"),
+			},
+			expectedNames: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name: "duplicated",
+			chunks: []apis.ListResponseEvent{
+				newListChunk(0, false, "10", "a"),
+				newListChunk(0, false, "10", "a"),
+				newListChunk(1, true, "10", "b"),
+				newListChunk(1, true, "10", "b"),
+			},
+			expectedNames: []string{"a", "b"},
+		},
+		{
+			name: "missing chunk",
+			chunks: []apis.ListResponseEvent{
+				newListChunk(0, false, "10", "a"),
+				newListChunk(2, true, "10", "c"),
+			},
+			missing: "missing chunks [1]",
+		},
+		{
+			name: "missing end of list",
+			chunks: []apis.ListResponseEvent{
+				newListChunk(1, false, "10", "b"),
+			},
+			missing: "missing chunks [0] and the end of list",
+		},
+		{
+			name: "different resource versions",
+			chunks: []apis.ListResponseEvent{
+				newListChunk(0, false, "10", "a"),
+				newListChunk(1, true, "11", "b"),
+			},
+			expectedError: "inconsistent",
+		},
+		{
+			name: "chunk after the end of list",
+			chunks: []apis.ListResponseEvent{
+				newListChunk(0, true, "10", "a"),
+				newListChunk(1, false, "10", "b"),
+			},
+			expectedError: "out of range",
+		},
+		{
+			name: "end of list before a received chunk",
+			chunks: []apis.ListResponseEvent{
+				newListChunk(2, false, "10", "c"),
+				newListChunk(1, true, "10", "b"),
+			},
+			expectedError: "after the end of list",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			chunks := newListChunks()
+
+			var err error
+			for _, chunk := range c.chunks {
+				if err = chunks.add(chunk); err != nil {
+					break
+				}
+			}
+
+			if len(c.expectedError) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+					t.Fatalf("expected error %q, got %v", c.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(c.missing) > 0 {
+				if chunks.completed() {
+					t.Fatalf("expected the list is not completed")
+				}
+				if missing := chunks.missing(); missing != c.missing {
+					t.Errorf("expected %q, got %q", c.missing, missing)
+				}
+				return
+			}
+
+			if !chunks.completed() {
+				t.Fatalf("expected the list is completed, %s", chunks.missing())
+			}
+			list := chunks.list()
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.GetName())
+			}
+			if strings.Join(names, ",") != strings.Join(c.expectedNames, ",") {
+				t.Errorf("expected items %v, got %v", c.expectedNames, names)
+			}
+			if list.GetResourceVersion() != "10" {
+				t.Errorf("expected resource version 10, got %q", list.GetResourceVersion())
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package informers_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiujian16/events-informer/pkg/informers"
+	"github.com/qiujian16/events-informer/pkg/senders"
+	eitesting "github.com/qiujian16/events-informer/pkg/testing"
+	"github.com/qiujian16/events-informer/pkg/transport"
+	"github.com/qiujian16/events-informer/pkg/transport/loopback"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TestListWatcherConcurrently sends the lists, watches and stops of the list watchers on the
+// same receiver concurrently, it is expected to run with the race detector.
+func TestListWatcherConcurrently(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects := []runtime.Object{}
+	for i := 0; i < 25; i++ {
+		objects = append(objects, eitesting.NewSecret("ns", fmt.Sprintf("secret-%d", i)))
+	}
+
+	bus := loopback.NewBus(loopback.Options{})
+	if err := eitesting.StartLoopbackSender(ctx, bus, eitesting.NewFakeClient(objects...), senders.WithListChunkSize(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventReceiver := informers.NewEventReceiver(receiver)
+	eventReceiver.Start(ctx)
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 100)
+	listWatchers := []*informers.EventListWatcher{}
+	for i := 0; i < 4; i++ {
+		lw := informers.NewEventListWatcher(ctx, fmt.Sprintf("client-%d", i), "ns", sender, eventReceiver, eitesting.SecretsGVR)
+		listWatchers = append(listWatchers, lw)
+
+		for j := 0; j < 5; j++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				list, err := lw.List(metav1.ListOptions{})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if items := len(list.(*unstructured.UnstructuredList).Items); items != len(objects) {
+					errs <- fmt.Errorf("expected %d items, got %d", len(objects), items)
+				}
+			}()
+
+			go func() {
+				defer wg.Done()
+				w, err := lw.Watch(metav1.ListOptions{})
+				if err != nil {
+					errs <- err
+					return
+				}
+				lw.ActiveWatches()
+				time.Sleep(10 * time.Millisecond)
+				w.Stop()
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, lw := range listWatchers {
+		if watches := lw.ActiveWatches(); len(watches) != 0 {
+			t.Errorf("expected no active watch, got %d", len(watches))
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package informers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	cache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// ClusterAnnotation is set on the objects of the multi-cluster informers with the cluster
+	// which the object comes from.
+	ClusterAnnotation = "events-informer.io/cluster"
+	// ClusterIndex is the index of the objects of the multi-cluster informers by cluster.
+	ClusterIndex = "cluster"
+
+	// multiClusterListWorkers is the number of clusters listed in parallel.
+	multiClusterListWorkers = 16
+	// clusterRetryPeriod is the interval of retrying to list or watch a cluster.
+	clusterRetryPeriod = 5 * time.Second
+)
+
+// ClusterIndexFunc indexes the objects of the multi-cluster informers by their cluster.
+func ClusterIndexFunc(obj interface{}) ([]string, error) {
+	accessor, err := metaAccessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, ok := accessor.GetAnnotations()[ClusterAnnotation]
+	if !ok {
+		return []string{}, nil
+	}
+	return []string{cluster}, nil
+}
+
+// ClusterNamespace returns the namespace of an object in the multi-cluster informers. The
+// namespace is prefixed with the cluster, so the objects with the same namespace and name
+// in different clusters have different keys. It is the cluster for a cluster scoped object.
+func ClusterNamespace(cluster, namespace string) string {
+	if len(namespace) == 0 {
+		return cluster
+	}
+	return cluster + "/" + namespace
+}
+
+// SplitClusterNamespace returns the cluster and the namespace in the cluster of the namespace
+// of an object in the multi-cluster informers.
+func SplitClusterNamespace(namespace string) (cluster, clusterNamespace string) {
+	parts := strings.SplitN(namespace, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// SplitClusterMetaNamespaceKey returns the cluster, the namespace in the cluster and the name
+// of the key of an object in the multi-cluster informers, the key is <cluster>/<namespace>/<name>,
+// or <cluster>/<name> for a cluster scoped object. Use it instead of cache.SplitMetaNamespaceKey,
+// which refuses the keys of the namespaced objects.
+func SplitClusterMetaNamespaceKey(key string) (cluster, namespace, name string, err error) {
+	parts := strings.Split(key, "/")
+	switch len(parts) {
+	case 2:
+		return parts[0], "", parts[1], nil
+	case 3:
+		return parts[0], parts[1], parts[2], nil
+	}
+
+	return "", "", "", fmt.Errorf("unexpected multi-cluster key format: %q", key)
+}
+
+// ClusterObject returns the cluster of an object in the multi-cluster informers, and a copy of
+// the object as it is in the cluster, i.e. with the namespace in the cluster and without the
+// cluster annotation. The object is returned as is with an empty cluster if it has no cluster
+// annotation.
+func ClusterObject(obj *unstructured.Unstructured) (string, *unstructured.Unstructured) {
+	cluster, ok := obj.GetAnnotations()[ClusterAnnotation]
+	if !ok {
+		return "", obj
+	}
+
+	clusterObj := obj.DeepCopy()
+	annotations := clusterObj.GetAnnotations()
+	delete(annotations, ClusterAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	clusterObj.SetAnnotations(annotations)
+
+	if objCluster, namespace := SplitClusterNamespace(clusterObj.GetNamespace()); objCluster == cluster {
+		clusterObj.SetNamespace(namespace)
+	}
+	return cluster, clusterObj
+}
+
+func metaAccessor(obj interface{}) (metav1.Object, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	accessor, ok := obj.(metav1.Object)
+	if !ok {
+		return nil, fmt.Errorf("object %T has no metadata", obj)
+	}
+	return accessor, nil
+}
+
+type multiClusterInformerFactory struct {
+	*eventSharedInformerFactory
+	clusters []string
+}
+
+// NewMultiClusterInformerFactory constructs a MultiClusterInformerFactory, the informers of
+// the factory are fed by the senders of the clusters. An object is set with the cluster
+// annotation, and its namespace is prefixed with the cluster, see ClusterNamespace. So the keys
+// of the objects are split with SplitClusterMetaNamespaceKey, and ClusterObject restores an
+// object as it is in its cluster before it is written back.
+func NewMultiClusterInformerFactory(ctx context.Context, sender, receiver cloudevents.Client, clusters []string, defaultResync time.Duration, options ...EventSharedInformerOption) MultiClusterInformerFactory {
+	factory := NewEventSharedInformerFactoryWithOptions(ctx, sender, receiver, defaultResync, options...)
+
+	return &multiClusterInformerFactory{
+		eventSharedInformerFactory: factory.(*eventSharedInformerFactory),
+		clusters:                   append([]string{}, clusters...),
+	}
+}
+
+var _ MultiClusterInformerFactory = &multiClusterInformerFactory{}
+
+func (f *multiClusterInformerFactory) Clusters() []string {
+	return append([]string{}, f.clusters...)
+}
+
+func (f *multiClusterInformerFactory) ForResource(gvr schema.GroupVersionResource) informers.GenericInformer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key := gvr
+	informer, exists := f.informers[key]
+	if exists {
+		return informer
+	}
+
+	informer = NewMultiClusterInformer(f.ctx, f.clientID, f.sender, f.receiver, f.clusters, gvr, f.namespace, f.defaultResync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc, ClusterIndex: ClusterIndexFunc}, f.tweakListOptions, f.listWatcherOptions...)
+	f.informers[key] = informer
+
+	return informer
+}
+
+// NewMultiClusterInformer builds an informer of the resource in the namespace of all the clusters.
+func NewMultiClusterInformer(
+	ctx context.Context,
+	source string,
+	sender cloudevents.Client,
+	receiver *EventReceiver,
+	clusters []string,
+	gvr schema.GroupVersionResource,
+	namespace string,
+	resyncPeriod time.Duration,
+	indexers cache.Indexers,
+	tweakListOptions dynamicinformer.TweakListOptionsFunc,
+	opts ...ListWatcherOption) informers.GenericInformer {
+	lw := &multiClusterListWatcher{
+		ctx:              ctx,
+		gvr:              gvr,
+		clusters:         clusters,
+		listWatchers:     map[string]*EventListWatcher{},
+		resourceVersions: map[string]string{},
+		lastLists:        map[string]*unstructured.UnstructuredList{},
+	}
+
+	for _, cluster := range clusters {
+		clusterOpts := append(append([]ListWatcherOption{}, opts...), ClusterName(cluster))
+		lw.listWatchers[cluster] = NewEventListWatcher(ctx, source, namespace, sender, receiver, gvr, clusterOpts...)
+	}
+
+	return newEventInformer(lw, gvr, resyncPeriod, indexers, tweakListOptions)
+}
+
+// multiClusterListWatcher lists and watches the resource of all the clusters. It keeps the
+// resource version of each cluster, the clusters are watched from their own resource versions
+// instead of the one requested by the reflector. It also keeps the last list of each cluster,
+// which stands in for the cluster when the cluster fails to list.
+type multiClusterListWatcher struct {
+	ctx          context.Context
+	gvr          schema.GroupVersionResource
+	clusters     []string
+	listWatchers map[string]*EventListWatcher
+
+	// lock guards the resource versions and the last lists of the listed clusters. A cluster
+	// without resource version is not listed yet, e.g. it is unreachable when the resource is
+	// listed.
+	lock             sync.Mutex
+	resourceVersions map[string]string
+	lastLists        map[string]*unstructured.UnstructuredList
+}
+
+func (m *multiClusterListWatcher) resourceVersion(cluster string) (string, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	rv, ok := m.resourceVersions[cluster]
+	return rv, ok
+}
+
+func (m *multiClusterListWatcher) setResourceVersion(cluster, rv string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.resourceVersions[cluster] = rv
+}
+
+// setListed records the list of the cluster, the cluster is watched from the resource version
+// of the list.
+func (m *multiClusterListWatcher) setListed(cluster string, objectList *unstructured.UnstructuredList) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.resourceVersions[cluster] = objectList.GetResourceVersion()
+	m.lastLists[cluster] = objectList
+}
+
+func (m *multiClusterListWatcher) lastList(cluster string) (*unstructured.UnstructuredList, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	objectList, ok := m.lastLists[cluster]
+	return objectList, ok
+}
+
+// List lists the resource of all the clusters in parallel. The last list of a cluster failed
+// to list is used instead, so the objects of the cluster are not removed by the reflector, and
+// the cluster is watched again from the resource version of the last list. A cluster failed to
+// list which is never listed is skipped and listed again by the watch. The list fails only if
+// no cluster is listed.
+func (m *multiClusterListWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	lists := make([]*unstructured.UnstructuredList, len(m.clusters))
+	errs := make([]error, len(m.clusters))
+
+	workqueue.ParallelizeUntil(m.ctx, multiClusterListWorkers, len(m.clusters), func(i int) {
+		cluster := m.clusters[i]
+
+		clusterOptions := options
+		if len(options.ResourceVersion) > 0 {
+			clusterOptions.ResourceVersion = "0"
+			if rv, ok := m.resourceVersion(cluster); ok {
+				clusterOptions.ResourceVersion = rv
+			}
+		}
+
+		lists[i], errs[i] = m.listCluster(cluster, clusterOptions)
+	})
+
+	resourceVersions := map[string]string{}
+	lastLists := map[string]*unstructured.UnstructuredList{}
+	objectList := &unstructured.UnstructuredList{}
+	for i, cluster := range m.clusters {
+		clusterList := lists[i]
+		if errs[i] != nil {
+			last, ok := m.lastList(cluster)
+			if !ok {
+				klog.Errorf("failed to list %s of cluster %s: %v", m.gvr, cluster, errs[i])
+				continue
+			}
+
+			klog.Errorf("failed to list %s of cluster %s, keep its last listed objects: %v", m.gvr, cluster, errs[i])
+			clusterList = last
+		}
+
+		resourceVersions[cluster] = clusterList.GetResourceVersion()
+		lastLists[cluster] = clusterList
+		for j := range clusterList.Items {
+			objectList.Items = append(objectList.Items, *clusterList.Items[j].DeepCopy())
+		}
+	}
+
+	if len(m.clusters) > 0 && len(resourceVersions) == 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+
+	m.lock.Lock()
+	m.resourceVersions = resourceVersions
+	m.lastLists = lastLists
+	m.lock.Unlock()
+
+	return objectList, nil
+}
+
+// listCluster lists the whole resource of the cluster, and sets the cluster on the objects.
+func (m *multiClusterListWatcher) listCluster(cluster string, options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	// the clusters are not paged together, each cluster is listed in chunks by its sender.
+	options.Limit = 0
+	options.Continue = ""
+
+	obj, err := m.listWatchers[cluster].List(options)
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		options.ResourceVersion = ""
+		obj, err = m.listWatchers[cluster].List(options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	objectList, ok := obj.(*unstructured.UnstructuredList)
+	if !ok {
+		return nil, fmt.Errorf("unexpected list %T of cluster %s", obj, cluster)
+	}
+
+	for i := range objectList.Items {
+		setCluster(&objectList.Items[i], cluster)
+	}
+	return objectList, nil
+}
+
+// Watch watches the resource of all the clusters. The watch of a cluster is restarted from
+// its resource version when it is ended, the aggregated watch is ended only when it is stopped
+// or a watch error is sent.
+func (m *multiClusterListWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w := &multiClusterWatcher{
+		result: make(chan watch.Event, 10),
+		stopCh: make(chan struct{}),
+	}
+
+	for _, cluster := range m.clusters {
+		w.wg.Add(1)
+		go func(cluster string) {
+			defer w.wg.Done()
+			m.watchCluster(w, cluster, options)
+		}(cluster)
+	}
+
+	go func() {
+		w.wg.Wait()
+		close(w.result)
+	}()
+
+	return w, nil
+}
+
+func (m *multiClusterListWatcher) watchCluster(w *multiClusterWatcher, cluster string, options metav1.ListOptions) {
+	for {
+		if _, listed := m.resourceVersion(cluster); !listed {
+			if err := m.addCluster(w, cluster, options); err != nil {
+				klog.Errorf("failed to list %s of cluster %s: %v", m.gvr, cluster, err)
+			}
+		} else if !m.forwardClusterEvents(w, cluster, options) {
+			return
+		}
+
+		select {
+		case <-w.stopCh:
+			return
+		case <-time.After(clusterRetryPeriod):
+		}
+	}
+}
+
+// addCluster lists the cluster which is not listed yet, and sends its objects as added.
+func (m *multiClusterListWatcher) addCluster(w *multiClusterWatcher, cluster string, options metav1.ListOptions) error {
+	options.ResourceVersion = ""
+	objectList, err := m.listCluster(cluster, options)
+	if err != nil {
+		return err
+	}
+
+	for i := range objectList.Items {
+		if !w.send(watch.Event{Type: watch.Added, Object: &objectList.Items[i]}) {
+			return nil
+		}
+	}
+
+	m.setListed(cluster, objectList)
+	return nil
+}
+
+// forwardClusterEvents watches the cluster from its resource version and forwards the events
+// until the watch of the cluster is ended. It returns false if the cluster should not be
+// watched again.
+func (m *multiClusterListWatcher) forwardClusterEvents(w *multiClusterWatcher, cluster string, options metav1.ListOptions) bool {
+	rv, _ := m.resourceVersion(cluster)
+	options.ResourceVersion = rv
+
+	clusterWatcher, err := m.listWatchers[cluster].Watch(options)
+	if err != nil {
+		klog.Errorf("failed to watch %s of cluster %s: %v", m.gvr, cluster, err)
+		return true
+	}
+	defer clusterWatcher.Stop()
+
+	for {
+		select {
+		case <-w.stopCh:
+			return false
+		case event, ok := <-clusterWatcher.ResultChan():
+			if !ok {
+				return true
+			}
+
+			switch event.Type {
+			case watch.Error:
+				// let the reflector decide how to recover, e.g. relist if the resource version is expired.
+				w.send(event)
+				return false
+			case watch.Bookmark:
+				if accessor, err := metaAccessor(event.Object); err == nil {
+					m.setResourceVersion(cluster, accessor.GetResourceVersion())
+				}
+				continue
+			}
+
+			obj, ok := event.Object.(*unstructured.Unstructured)
+			if !ok {
+				continue
+			}
+
+			m.setResourceVersion(cluster, obj.GetResourceVersion())
+			setCluster(obj, cluster)
+			if !w.send(event) {
+				return false
+			}
+		}
+	}
+}
+
+// setCluster sets the cluster annotation and prefixes the namespace with the cluster.
+func setCluster(obj *unstructured.Unstructured, cluster string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ClusterAnnotation] = cluster
+	obj.SetAnnotations(annotations)
+	obj.SetNamespace(ClusterNamespace(cluster, obj.GetNamespace()))
+}
+
+type multiClusterWatcher struct {
+	result chan watch.Event
+	stopCh chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+func (w *multiClusterWatcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+func (w *multiClusterWatcher) Stop() {
+	w.once.Do(func() {
+		close(w.stopCh)
+	})
+}
+
+// send sends the event unless the watcher is stopped, it returns false if the watcher is stopped.
+func (w *multiClusterWatcher) send(event watch.Event) bool {
+	select {
+	case w.result <- event:
+		return true
+	case <-w.stopCh:
+		return false
+	}
+}
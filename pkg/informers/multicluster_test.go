@@ -0,0 +1,153 @@
+package informers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/qiujian16/events-informer/pkg/senders"
+	"github.com/qiujian16/events-informer/pkg/transport"
+	"github.com/qiujian16/events-informer/pkg/transport/loopback"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestClusterKeys(t *testing.T) {
+	cases := []struct {
+		name              string
+		namespace         string
+		expectedNamespace string
+	}{
+		{name: "namespaced", namespace: "ns", expectedNamespace: "ns"},
+		{name: "cluster scoped"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{}
+			obj.SetName("a")
+			obj.SetNamespace(c.namespace)
+			obj.SetLabels(map[string]string{"app": "a"})
+			setCluster(obj, "cluster1")
+
+			key, err := cache.MetaNamespaceKeyFunc(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cluster, namespace, name, err := SplitClusterMetaNamespaceKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cluster != "cluster1" || namespace != c.expectedNamespace || name != "a" {
+				t.Errorf("unexpected split of key %q: %q, %q, %q", key, cluster, namespace, name)
+			}
+
+			cluster, clusterObj := ClusterObject(obj)
+			if cluster != "cluster1" {
+				t.Errorf("expected cluster %q, got %q", "cluster1", cluster)
+			}
+			if clusterObj.GetNamespace() != c.namespace {
+				t.Errorf("expected namespace %q, got %q", c.namespace, clusterObj.GetNamespace())
+			}
+			if annotations := clusterObj.GetAnnotations(); len(annotations) != 0 {
+				t.Errorf("expected no annotation, got %v", annotations)
+			}
+			if clusterObj.GetLabels()["app"] != "a" {
+				t.Errorf("expected the labels are kept, got %v", clusterObj.GetLabels())
+			}
+			if obj.GetAnnotations()[ClusterAnnotation] != "cluster1" {
+				t.Errorf("expected the object in the informer is not changed")
+			}
+		})
+	}
+
+	if _, _, _, err := SplitClusterMetaNamespaceKey("a"); err == nil {
+		t.Errorf("expected error of a key without cluster")
+	}
+}
+
+// TestMultiClusterListKeepsFailedCluster lists the clusters after the sender of a cluster is
+// stopped, the last listed objects of the cluster are kept, and a cluster which is never
+// listed is skipped.
+func TestMultiClusterListKeepsFailedCluster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := loopback.NewBus(loopback.Options{})
+	stopSenders := map[string]context.CancelFunc{}
+	for _, cluster := range []string{"cluster1", "cluster2"} {
+		secret := &unstructured.Unstructured{}
+		secret.SetAPIVersion("v1")
+		secret.SetKind("Secret")
+		secret.SetNamespace("ns")
+		secret.SetName("secret-" + cluster)
+		client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{secretsGVR: "SecretList"}, secret)
+
+		senderCtx, stopSender := context.WithCancel(ctx)
+		stopSenders[cluster] = stopSender
+		sender, receiver, err := bus.NewClients(senderCtx, transport.DefaultResponseTopic, transport.DefaultRequestTopic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		senderTransport := senders.NewDefaultSenderTansport(senders.NewDynamicSender(client), sender, receiver, senders.WithClusterName(cluster))
+		go senderTransport.Run(senderCtx)
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventReceiver := NewEventReceiver(receiver)
+	eventReceiver.Start(ctx)
+
+	// cluster3 has no sender, it is never listed.
+	clusters := []string{"cluster1", "cluster2", "cluster3"}
+	lw := &multiClusterListWatcher{
+		ctx:              ctx,
+		gvr:              secretsGVR,
+		clusters:         clusters,
+		listWatchers:     map[string]*EventListWatcher{},
+		resourceVersions: map[string]string{},
+		lastLists:        map[string]*unstructured.UnstructuredList{},
+	}
+	for _, cluster := range clusters {
+		lw.listWatchers[cluster] = NewEventListWatcher(ctx, "client", "ns", sender, eventReceiver, secretsGVR,
+			ClusterName(cluster), RequestTimeout(time.Second))
+	}
+
+	expectSecrets := func(expected ...string) {
+		t.Helper()
+
+		obj, err := lw.List(metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, item := range obj.(*unstructured.UnstructuredList).Items {
+			names = append(names, item.GetName())
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("expected secrets %v, got %v", expected, names)
+		}
+	}
+
+	expectSecrets("secret-cluster1", "secret-cluster2")
+	rv, _ := lw.resourceVersion("cluster2")
+
+	stopSenders["cluster2"]()
+	expectSecrets("secret-cluster1", "secret-cluster2")
+	if keptRV, ok := lw.resourceVersion("cluster2"); !ok || keptRV != rv {
+		t.Errorf("expected the resource version %q of the last list of cluster2, got %q", rv, keptRV)
+	}
+	if _, ok := lw.resourceVersion("cluster3"); ok {
+		t.Errorf("expected cluster3 is not listed")
+	}
+}
@@ -0,0 +1,232 @@
+package informers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/apis"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/klog/v2"
+)
+
+// EventReceiver owns the single receive loop of a cloudevents client. A cloudevents
+// client only accepts one receiver, so all the list watchers built on the same client
+// register to the EventReceiver and the response events are dispatched to them by gvr.
+type EventReceiver struct {
+	receiver cloudevents.Client
+
+	lock     sync.RWMutex
+	handlers map[schema.GroupVersionResource][]*EventListWatcher
+
+	// loopLock guards the users and the receive loop. A user is the context of a caller, the
+	// loop runs while the context of any user is not done, so a user done with the receiver
+	// does not stop the receiving of others.
+	loopLock sync.Mutex
+	users    map[context.Context]struct{}
+	// cancel stops the running loop, it is nil if no loop is running.
+	cancel context.CancelFunc
+	// stopped is closed when the last started loop returns.
+	stopped chan struct{}
+	// loops is the number of the loops which are not returned yet, including a stopping one.
+	loops int
+	// idle is called when the receiver has no user and no loop.
+	idle func()
+}
+
+var (
+	sharedReceiversLock sync.Mutex
+	sharedReceivers     = map[cloudevents.Client]*EventReceiver{}
+)
+
+// sharedEventReceiver returns the EventReceiver of the client used until the context is done.
+// The factories built on the same client, e.g. the factories of many clusters, share the
+// EventReceiver, since a client only accepts one receiver. The EventReceiver is dropped when
+// the contexts of all its users are done.
+func sharedEventReceiver(ctx context.Context, receiver cloudevents.Client) *EventReceiver {
+	sharedReceiversLock.Lock()
+	defer sharedReceiversLock.Unlock()
+
+	r, ok := sharedReceivers[receiver]
+	if !ok {
+		r = NewEventReceiver(receiver)
+		r.idle = func() {
+			sharedReceiversLock.Lock()
+			defer sharedReceiversLock.Unlock()
+
+			if sharedReceivers[receiver] == r && r.isIdle() {
+				delete(sharedReceivers, receiver)
+			}
+		}
+		sharedReceivers[receiver] = r
+	}
+
+	r.acquire(ctx)
+	return r
+}
+
+func NewEventReceiver(receiver cloudevents.Client) *EventReceiver {
+	return &EventReceiver{
+		receiver: receiver,
+		handlers: map[schema.GroupVersionResource][]*EventListWatcher{},
+		users:    map[context.Context]struct{}{},
+	}
+}
+
+// Start starts the receive loop if it is not running. The loop runs until the contexts of
+// all the callers of Start, and of the factories sharing the receiver, are done.
+func (r *EventReceiver) Start(ctx context.Context) {
+	if !r.acquire(ctx) {
+		return
+	}
+
+	r.loopLock.Lock()
+	defer r.loopLock.Unlock()
+
+	if r.cancel != nil {
+		return
+	}
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	previous, stopped := r.stopped, make(chan struct{})
+	r.cancel, r.stopped = cancel, stopped
+	r.loops++
+
+	go func() {
+		defer r.loopStopped(stopped)
+
+		// a client only accepts one receiver, wait for the stopping loop to return.
+		if previous != nil {
+			<-previous
+		}
+
+		if err := r.receiver.StartReceiver(loopCtx, r.dispatch); err != nil {
+			utilruntime.HandleError(fmt.Errorf("failed to start receiver: %v", err))
+		}
+	}()
+}
+
+// acquire adds the context as a user of the receiver until it is done, a context is added
+// only once however many times it is acquired. It returns false if the context is already
+// done.
+func (r *EventReceiver) acquire(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	r.loopLock.Lock()
+	_, ok := r.users[ctx]
+	r.users[ctx] = struct{}{}
+	r.loopLock.Unlock()
+
+	if !ok {
+		go func() {
+			<-ctx.Done()
+			r.release(ctx)
+		}()
+	}
+	return true
+}
+
+// release removes a user, the loop is stopped when there is no user.
+func (r *EventReceiver) release(ctx context.Context) {
+	r.loopLock.Lock()
+	delete(r.users, ctx)
+	if len(r.users) == 0 && r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	idle := len(r.users) == 0 && r.loops == 0
+	r.loopLock.Unlock()
+
+	if idle && r.idle != nil {
+		r.idle()
+	}
+}
+
+func (r *EventReceiver) loopStopped(stopped chan struct{}) {
+	r.loopLock.Lock()
+	close(stopped)
+	r.loops--
+	idle := len(r.users) == 0 && r.loops == 0
+	r.loopLock.Unlock()
+
+	if idle && r.idle != nil {
+		r.idle()
+	}
+}
+
+func (r *EventReceiver) isIdle() bool {
+	r.loopLock.Lock()
+	defer r.loopLock.Unlock()
+
+	return len(r.users) == 0 && r.loops == 0
+}
+
+func (r *EventReceiver) register(lw *EventListWatcher) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.handlers[lw.gvr] = append(r.handlers[lw.gvr], lw)
+}
+
+func (r *EventReceiver) unregister(lw *EventListWatcher) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	handlers := []*EventListWatcher{}
+	for _, handler := range r.handlers[lw.gvr] {
+		if handler != lw {
+			handlers = append(handlers, handler)
+		}
+	}
+
+	if len(handlers) == 0 {
+		delete(r.handlers, lw.gvr)
+		return
+	}
+	r.handlers[lw.gvr] = handlers
+}
+
+func (r *EventReceiver) dispatch(evt cloudevents.Event) error {
+	klog.Infof("received response event %s, %v", evt.Type(), evt)
+
+	if evt.Type() == apis.EventSenderStartedType {
+		r.lock.RLock()
+		handlers := []*EventListWatcher{}
+		for _, lws := range r.handlers {
+			handlers = append(handlers, lws...)
+		}
+		r.lock.RUnlock()
+
+		for _, lw := range handlers {
+			lw.senderStarted(evt)
+		}
+		return nil
+	}
+
+	mode, gvr, err := apis.ParseEventType(evt.Type())
+	if err != nil {
+		return err
+	}
+
+	if !apis.IsResponseMode(mode) {
+		return nil
+	}
+
+	r.lock.RLock()
+	handlers := r.handlers[gvr]
+	r.lock.RUnlock()
+
+	errs := []error{}
+	for _, lw := range handlers {
+		if err := lw.process(mode, evt); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
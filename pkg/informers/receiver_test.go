@@ -0,0 +1,67 @@
+package informers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReceiverAddsCallerOnce(t *testing.T) {
+	r := NewEventReceiver(nil)
+	idle := make(chan struct{})
+	r.idle = func() { close(idle) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 3; i++ {
+		if !r.acquire(ctx) {
+			t.Fatalf("expected the context to be acquired")
+		}
+	}
+
+	r.loopLock.Lock()
+	users := len(r.users)
+	r.loopLock.Unlock()
+	if users != 1 {
+		t.Errorf("expected 1 user, got %d", users)
+	}
+
+	cancel()
+	select {
+	case <-idle:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the receiver to be idle when the caller is done")
+	}
+
+	if r.acquire(ctx) {
+		t.Errorf("expected a done context not to be acquired")
+	}
+}
+
+// TestClientListWatcherUnregistered checks the list watcher built for a call of the dynamic
+// client no longer receives once the call is done.
+func TestClientListWatcherUnregistered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &eventDynamicClient{ctx: ctx, source: "client", receiver: NewEventReceiver(nil)}
+	handlers := func() int {
+		client.receiver.lock.RLock()
+		defer client.receiver.lock.RUnlock()
+		return len(client.receiver.handlers[secretsGVR])
+	}
+
+	_, done := client.listWatcher(secretsGVR, "ns", "")
+	if n := handlers(); n != 1 {
+		t.Fatalf("expected 1 list watcher, got %d", n)
+	}
+
+	done()
+	timeout := time.After(5 * time.Second)
+	for handlers() != 0 {
+		select {
+		case <-timeout:
+			t.Fatalf("expected the list watcher is unregistered when the call is done")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package informers
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// pendingRequest is a pending list or write request. The results are delivered to the request
+// until it is done, results arriving after that, e.g. late chunks after a timeout, are dropped.
+type pendingRequest struct {
+	results chan requestResult
+	done    chan struct{}
+}
+
+// pendingRequests is the table of the pending requests keyed by the request id. It is
+// safe to add, remove and deliver to the requests concurrently.
+type pendingRequests struct {
+	lock     sync.RWMutex
+	requests map[types.UID]*pendingRequest
+}
+
+func newPendingRequests() *pendingRequests {
+	return &pendingRequests{
+		requests: map[types.UID]*pendingRequest{},
+	}
+}
+
+// add registers a request, the request must be removed when it is done.
+func (r *pendingRequests) add(uid types.UID) *pendingRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	request := &pendingRequest{
+		results: make(chan requestResult),
+		done:    make(chan struct{}),
+	}
+	r.requests[uid] = request
+	return request
+}
+
+// remove unregisters the request and unblocks the pending deliveries to it.
+func (r *pendingRequests) remove(uid types.UID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if request, ok := r.requests[uid]; ok {
+		close(request.done)
+		delete(r.requests, uid)
+	}
+}
+
+// has returns true if the request is pending.
+func (r *pendingRequests) has(uid types.UID) bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	_, ok := r.requests[uid]
+	return ok
+}
+
+// deliver delivers the result to the request, it blocks until the result is received
+// or the request is done. The result is dropped if the request is not pending.
+func (r *pendingRequests) deliver(uid types.UID, result requestResult) {
+	r.lock.RLock()
+	request, ok := r.requests[uid]
+	r.lock.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	select {
+	case request.results <- result:
+	case <-request.done:
+	}
+}
@@ -0,0 +1,44 @@
+package informers
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestPendingRequestsConcurrently(t *testing.T) {
+	requests := newPendingRequests()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		uid := types.UID(fmt.Sprintf("request-%d", i))
+		request := requests.add(uid)
+
+		// the results are delivered concurrently, some of them after the request is removed.
+		for j := 0; j < 5; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				requests.deliver(uid, requestResult{})
+			}()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer requests.remove(uid)
+			for j := 0; j < 3; j++ {
+				<-request.results
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 50; i++ {
+		if requests.has(types.UID(fmt.Sprintf("request-%d", i))) {
+			t.Errorf("expected request-%d is removed", i)
+		}
+	}
+}
@@ -2,27 +2,89 @@ package informers
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/qiujian16/events-informer/pkg/apis"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
 )
 
+// maxPendingWatchEvents is the max number of the watch events received ahead of a missing
+// event. The missing event is regarded as lost when it is exceeded, the watch is stopped so
+// the consumer watches again from the last resource version it has seen.
+const maxPendingWatchEvents = 100
+
+// watchTimeoutMargin is the time waited after the timeout of a watch before the watcher ends
+// the watch by itself. The sender ends the watch on the timeout, but the end is lost if the
+// sender dies, e.g. when its start announcement is lost as well.
+var watchTimeoutMargin = 30 * time.Second
+
 type eventWatcher struct {
-	uid    types.UID
-	gvr    schema.GroupVersionResource
-	stop   func()
-	result chan watch.Event
+	uid       types.UID
+	gvr       schema.GroupVersionResource
+	options   metav1.ListOptions
+	startTime time.Time
+	stop      func()
+	once      sync.Once
+	result    chan watch.Event
+
+	// stopCh is closed when the watcher is stopped or the watch is ended by the sender,
+	// lock guards the result chan from being closed while sending.
+	stopCh chan struct{}
+	lock   sync.Mutex
+	closed bool
+
+	// senderID is the instance id of the sender serving the watch, it is unknown until the
+	// first watch response is received.
+	senderID atomic.Value
+
+	// seqLock guards the sequence of the next watch event and the events received ahead of it.
+	// The events are received concurrently, they are sent to the result chan in sequence.
+	seqLock sync.Mutex
+	nextSeq int64
+	pending map[int64]*apis.WatchResponseEvent
 }
 
-func newEventWatcher(uid types.UID, stop func(), gvr schema.GroupVersionResource, chanSize int) *eventWatcher {
-	return &eventWatcher{
-		uid:    uid,
-		gvr:    gvr,
-		result: make(chan watch.Event, chanSize),
-		stop:   stop,
+func newEventWatcher(uid types.UID, stop func(), gvr schema.GroupVersionResource, options metav1.ListOptions, chanSize int) *eventWatcher {
+	w := &eventWatcher{
+		uid:       uid,
+		gvr:       gvr,
+		options:   options,
+		startTime: time.Now(),
+		result:    make(chan watch.Event, chanSize),
+		stop:      stop,
+		stopCh:    make(chan struct{}),
+		nextSeq:   1,
+		pending:   map[int64]*apis.WatchResponseEvent{},
+	}
+
+	if options.TimeoutSeconds != nil && *options.TimeoutSeconds > 0 {
+		go w.stopOnTimeout(time.Duration(*options.TimeoutSeconds)*time.Second + watchTimeoutMargin)
+	}
+	return w
+}
+
+// stopOnTimeout stops the watch if it is not ended by the sender in time, so the consumer,
+// e.g. the reflector, watches again.
+func (w *eventWatcher) stopOnTimeout(timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		klog.Warningf("watch %s of %s is not ended by the sender after %v, stop it", w.uid, w.gvr, timeout)
+		w.Stop()
+	case <-w.stopCh:
 	}
 }
 
@@ -30,11 +92,74 @@ func (w *eventWatcher) ResultChan() <-chan watch.Event {
 	return w.result
 }
 
+// Stop requests the sender to stop the watch and closes the result chan. It is safe to
+// call Stop multiple times.
 func (w *eventWatcher) Stop() {
-	w.stop()
+	w.once.Do(func() {
+		close(w.stopCh)
+		w.stop()
+	})
+	w.closeResult()
+}
+
+// end closes the result chan when the watch is ended by the sender, so the consumer, e.g.
+// the reflector, watches again from its last resource version or lists again on error.
+// The watch is already stopped on the sender, so no stop request is needed.
+func (w *eventWatcher) end() {
+	w.once.Do(func() {
+		close(w.stopCh)
+	})
+	w.closeResult()
+}
+
+// stopped returns true if the watcher is stopped or the watch is ended by the sender.
+func (w *eventWatcher) stopped() bool {
+	select {
+	case <-w.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// servedByOther returns true if the watch is served by another instance of the sender. It
+// is unknown before the watch is acknowledged, the watch might be served by the instance.
+func (w *eventWatcher) servedByOther(senderID string) bool {
+	id, _ := w.senderID.Load().(string)
+	return len(id) > 0 && id != senderID
+}
+
+func (w *eventWatcher) closeResult() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.closed {
+		w.closed = true
+		close(w.result)
+	}
 }
 
 func (w *eventWatcher) convertToWatchEvent(event *apis.WatchResponseEvent) *watch.Event {
+	if len(event.Type) == 0 {
+		return nil
+	}
+
+	if event.Type == watch.Error {
+		return &watch.Event{
+			Type:   watch.Error,
+			Object: toStatus(event.Object),
+		}
+	}
+
+	// the bookmarks are surfaced only if they are allowed, they carry the resource version only.
+	if event.Type == watch.Bookmark && !w.options.AllowWatchBookmarks {
+		return nil
+	}
+
+	if event.Object == nil {
+		return nil
+	}
+
 	return &watch.Event{
 		Type:   event.Type,
 		Object: event.Object,
@@ -48,7 +173,17 @@ func (w *eventWatcher) sendWatchCacheEvent(event *apis.WatchResponseEvent) {
 		return
 	}
 
-	w.result <- *watchEvent
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.result <- *watchEvent:
+	case <-w.stopCh:
+	}
 }
 
 func (w *eventWatcher) process(event cloudevents.Event) error {
@@ -60,12 +195,63 @@ func (w *eventWatcher) process(event cloudevents.Event) error {
 		return nil
 	}
 
+	w.senderID.Store(apis.SenderID(event))
+
 	response := &apis.WatchResponseEvent{}
 	err := json.Unmarshal(event.Data(), response)
 	if err != nil {
 		return err
 	}
 
+	w.seqLock.Lock()
+	defer w.seqLock.Unlock()
+
+	if response.Sequence == 0 {
+		w.handle(response)
+		return nil
+	}
+
+	// the event is delivered more than once.
+	if response.Sequence < w.nextSeq {
+		return nil
+	}
+
+	w.pending[response.Sequence] = response
+	if len(w.pending) > maxPendingWatchEvents {
+		klog.Warningf("watch event %d of %s %s is lost, stop the watch", w.nextSeq, w.gvr, w.uid)
+		w.Stop()
+		return nil
+	}
+
+	for {
+		next, ok := w.pending[w.nextSeq]
+		if !ok {
+			return nil
+		}
+		delete(w.pending, w.nextSeq)
+		w.nextSeq++
+		w.handle(next)
+	}
+}
+
+func (w *eventWatcher) handle(response *apis.WatchResponseEvent) {
 	w.sendWatchCacheEvent(response)
-	return nil
+
+	if response.EndOfWatch {
+		w.end()
+	}
+}
+
+// toStatus converts the object of an error event to metav1.Status, so the consumer can
+// tell the reason of the error, e.g. 410 Gone.
+func toStatus(obj *unstructured.Unstructured) *metav1.Status {
+	if obj == nil {
+		return &apierrors.NewInternalError(fmt.Errorf("watch error without status")).ErrStatus
+	}
+
+	status := &metav1.Status{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, status); err != nil {
+		return &apierrors.NewInternalError(err).ErrStatus
+	}
+	return status
 }
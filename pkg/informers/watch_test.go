@@ -0,0 +1,107 @@
+package informers
+
+import (
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/apis"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var secretsGVR = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+func newWatchResponse(t *testing.T, uid types.UID, response *apis.WatchResponseEvent) cloudevents.Event {
+	evt := cloudevents.NewEvent()
+	evt.SetID(string(uid))
+	evt.SetType(apis.EventWatchResponseType(secretsGVR))
+	evt.SetSource("sender")
+	if err := evt.SetData(cloudevents.ApplicationJSON, response); err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
+
+func newAddedResponse(name string, sequence int64) *apis.WatchResponseEvent {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("Secret")
+	obj.SetName(name)
+	return &apis.WatchResponseEvent{Type: watch.Added, Object: obj, Sequence: sequence}
+}
+
+func TestWatcherOrdersEvents(t *testing.T) {
+	w := newEventWatcher("watch", func() {}, secretsGVR, metav1.ListOptions{}, 10)
+
+	responses := []*apis.WatchResponseEvent{
+		newAddedResponse("b", 3),
+		{Sequence: 1},
+		newAddedResponse("c", 4),
+		// delivered more than once.
+		{Sequence: 1},
+		newAddedResponse("a", 2),
+		newAddedResponse("b", 3),
+		{EndOfWatch: true, Sequence: 5},
+	}
+	for _, response := range responses {
+		if err := w.process(newWatchResponse(t, "watch", response)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := []string{}
+	for event := range w.ResultChan() {
+		names = append(names, event.Object.(*unstructured.Unstructured).GetName())
+	}
+	if len(names) != 3 || names[0] != "a" || names[1] != "b" || names[2] != "c" {
+		t.Errorf("expected the events of a, b and c in order, got %v", names)
+	}
+}
+
+func TestWatcherStopsOnLostEvent(t *testing.T) {
+	stopped := false
+	w := newEventWatcher("watch", func() { stopped = true }, secretsGVR, metav1.ListOptions{}, maxPendingWatchEvents+1)
+
+	// the event 1 is lost.
+	for i := int64(2); i <= maxPendingWatchEvents+2; i++ {
+		if err := w.process(newWatchResponse(t, "watch", newAddedResponse("a", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !stopped {
+		t.Errorf("expected the watch is stopped on the sender")
+	}
+	if _, ok := <-w.ResultChan(); ok {
+		t.Errorf("expected no event is sent after the lost one")
+	}
+}
+
+func TestWatcherStopsOnTimeout(t *testing.T) {
+	margin := watchTimeoutMargin
+	watchTimeoutMargin = 0
+	defer func() { watchTimeoutMargin = margin }()
+
+	stopped := make(chan struct{})
+	timeoutSeconds := int64(1)
+	w := newEventWatcher("watch", func() { close(stopped) }, secretsGVR, metav1.ListOptions{TimeoutSeconds: &timeoutSeconds}, 10)
+
+	select {
+	case _, ok := <-w.ResultChan():
+		if ok {
+			t.Fatalf("expected no event")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the watch is ended after the timeout")
+	}
+
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("expected the watch is stopped on the sender")
+	}
+}
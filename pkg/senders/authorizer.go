@@ -0,0 +1,181 @@
+package senders
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/qiujian16/events-informer/pkg/apis"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Attributes are the attributes of a request evaluated by the authorizer.
+type Attributes struct {
+	// Source is the identity of the requester, it is the source of the request event,
+	// e.g. the client id of the informer.
+	Source string
+	// Verb is the verb of the request as in the kubernetes rbac, i.e. list, watch, get,
+	// create, update, patch or delete. An updatestatus request is an update of the status
+	// subresource and an apply request is a patch.
+	Verb        string
+	GVR         schema.GroupVersionResource
+	Subresource string
+	Namespace   string
+	// Name is the name of the object of a get or a write request, it is empty for the lists
+	// and watches.
+	Name          string
+	LabelSelector string
+	FieldSelector string
+}
+
+// Authorizer decides whether a request is answered by the sender. A denied request is answered
+// with a Forbidden error, the reason is returned to the requester.
+type Authorizer interface {
+	Authorize(attributes *Attributes) (allowed bool, reason string)
+}
+
+// AuthorizationRules is the rule file of the RuleAuthorizer, a request is allowed if it matches
+// any of the rules.
+type AuthorizationRules struct {
+	Rules []AuthorizationRule `json:"rules"`
+}
+
+// AuthorizationRule allows the requests matching all of its fields. "*" matches any value,
+// the sources are matched as the shell patterns, e.g. "syncer-*".
+type AuthorizationRule struct {
+	// Sources are the identities of the requesters, they are required.
+	Sources []string `json:"sources"`
+	// Verbs are the verbs of the requests, they are required.
+	Verbs []string `json:"verbs"`
+	// APIGroups are the groups of the resources, "" is the core group. They are required.
+	APIGroups []string `json:"apiGroups"`
+	// Resources are the resources, a subresource is matched as "<resource>/<subresource>",
+	// e.g. "deployments/status". They are required.
+	Resources []string `json:"resources"`
+	// Namespaces are the namespaces of the requests, all the namespaces are matched if it is
+	// empty. A request of all the namespaces is matched only by "*".
+	Namespaces []string `json:"namespaces,omitempty"`
+	// LabelSelectors restricts the lists and watches to the label selectors, a list or a watch
+	// is matched only if its label selector is one of them. Any label selector is matched if
+	// it is empty.
+	LabelSelectors []string `json:"labelSelectors,omitempty"`
+}
+
+type ruleAuthorizer struct {
+	rules []AuthorizationRule
+}
+
+// NewRuleAuthorizer builds an Authorizer allowing the requests matched by any of the rules,
+// all the other requests are denied.
+func NewRuleAuthorizer(rules *AuthorizationRules) (Authorizer, error) {
+	a := &ruleAuthorizer{}
+	for i, rule := range rules.Rules {
+		if len(rule.Sources) == 0 || len(rule.Verbs) == 0 || len(rule.APIGroups) == 0 || len(rule.Resources) == 0 {
+			return nil, fmt.Errorf("rule %d: sources, verbs, apiGroups and resources are required", i)
+		}
+		for _, source := range rule.Sources {
+			if _, err := path.Match(source, ""); err != nil {
+				return nil, fmt.Errorf("rule %d: invalid source %q: %v", i, source, err)
+			}
+		}
+
+		// the label selectors are normalized, so the same selectors written differently match.
+		selectors := []string{}
+		for _, selector := range rule.LabelSelectors {
+			parsed, err := labels.Parse(selector)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid label selector %q: %v", i, selector, err)
+			}
+			selectors = append(selectors, parsed.String())
+		}
+		rule.LabelSelectors = selectors
+
+		a.rules = append(a.rules, rule)
+	}
+	return a, nil
+}
+
+// LoadRuleAuthorizer reads the rules from a yaml or json file and builds the Authorizer.
+func LoadRuleAuthorizer(path string) (Authorizer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &AuthorizationRules{}
+	if err := yaml.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse authorization rules %s: %v", path, err)
+	}
+	return NewRuleAuthorizer(rules)
+}
+
+func (a *ruleAuthorizer) Authorize(attributes *Attributes) (bool, string) {
+	for _, rule := range a.rules {
+		if rule.matches(attributes) {
+			return true, ""
+		}
+	}
+	return false, "no authorization rule allows the request"
+}
+
+func (r *AuthorizationRule) matches(attributes *Attributes) bool {
+	resource := attributes.GVR.Resource
+	if len(attributes.Subresource) > 0 {
+		resource = resource + "/" + attributes.Subresource
+	}
+
+	if !matchesSource(r.Sources, attributes.Source) ||
+		!matchesValue(r.Verbs, attributes.Verb) ||
+		!matchesValue(r.APIGroups, attributes.GVR.Group) ||
+		!matchesValue(r.Resources, resource) {
+		return false
+	}
+
+	if len(r.Namespaces) > 0 && !matchesValue(r.Namespaces, attributes.Namespace) {
+		return false
+	}
+
+	if len(r.LabelSelectors) > 0 && (attributes.Verb == "list" || attributes.Verb == "watch") {
+		selector, err := labels.Parse(attributes.LabelSelector)
+		if err != nil || !matchesValue(r.LabelSelectors, selector.String()) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesSource(patterns []string, source string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if matched, _ := path.Match(pattern, source); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// requestVerb returns the rbac verb and the subresource of a request mode.
+func requestVerb(mode string, subresources []string) (string, string) {
+	subresource := strings.Join(subresources, "/")
+	switch mode {
+	case apis.ModeUpdateStatus:
+		return "update", "status"
+	case apis.ModeApply:
+		return "patch", subresource
+	}
+	return mode, subresource
+}
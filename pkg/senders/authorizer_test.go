@@ -0,0 +1,230 @@
+package senders
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiujian16/events-informer/pkg/apis"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	secretsGVR     = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+)
+
+func TestRuleAuthorizer(t *testing.T) {
+	authorizer, err := NewRuleAuthorizer(&AuthorizationRules{Rules: []AuthorizationRule{
+		{
+			Sources:    []string{"syncer-*"},
+			Verbs:      []string{"list", "watch", "get"},
+			APIGroups:  []string{""},
+			Resources:  []string{"secrets"},
+			Namespaces: []string{"ns"},
+			// written differently from the selector of the requests.
+			LabelSelectors: []string{"app=a,env in (prod)"},
+		},
+		{
+			Sources:   []string{"agent"},
+			Verbs:     []string{"update", "patch"},
+			APIGroups: []string{"apps"},
+			Resources: []string{"deployments/status"},
+		},
+		{
+			Sources:    []string{"admin"},
+			Verbs:      []string{"*"},
+			APIGroups:  []string{"*"},
+			Resources:  []string{"*"},
+			Namespaces: []string{"*"},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		attributes Attributes
+		allowed    bool
+	}{
+		{
+			name:       "source pattern",
+			attributes: Attributes{Source: "syncer-1", Verb: "get", GVR: secretsGVR, Namespace: "ns", Name: "a"},
+			allowed:    true,
+		},
+		{
+			name:       "source not matching the pattern",
+			attributes: Attributes{Source: "other-syncer-1", Verb: "get", GVR: secretsGVR, Namespace: "ns", Name: "a"},
+		},
+		{
+			name:       "verb not allowed",
+			attributes: Attributes{Source: "syncer-1", Verb: "delete", GVR: secretsGVR, Namespace: "ns", Name: "a"},
+		},
+		{
+			name:       "other group",
+			attributes: Attributes{Source: "syncer-1", Verb: "get", GVR: schema.GroupVersionResource{Group: "example.io", Version: "v1", Resource: "secrets"}, Namespace: "ns", Name: "a"},
+		},
+		{
+			name:       "other namespace",
+			attributes: Attributes{Source: "syncer-1", Verb: "get", GVR: secretsGVR, Namespace: "other", Name: "a"},
+		},
+		{
+			name:       "all namespaces not matched by a namespace",
+			attributes: Attributes{Source: "syncer-1", Verb: "list", GVR: secretsGVR, LabelSelector: "app=a,env in (prod)"},
+		},
+		{
+			name:       "all namespaces matched by any namespace",
+			attributes: Attributes{Source: "admin", Verb: "list", GVR: secretsGVR},
+			allowed:    true,
+		},
+		{
+			name:       "equivalent label selector",
+			attributes: Attributes{Source: "syncer-1", Verb: "list", GVR: secretsGVR, Namespace: "ns", LabelSelector: "env in (prod), app = a"},
+			allowed:    true,
+		},
+		{
+			name:       "watch with the label selector",
+			attributes: Attributes{Source: "syncer-1", Verb: "watch", GVR: secretsGVR, Namespace: "ns", LabelSelector: "app=a,env in (prod)"},
+			allowed:    true,
+		},
+		{
+			name:       "label selector with other operators",
+			attributes: Attributes{Source: "syncer-1", Verb: "watch", GVR: secretsGVR, Namespace: "ns", LabelSelector: "app=a,env=prod"},
+		},
+		{
+			name:       "narrower label selector",
+			attributes: Attributes{Source: "syncer-1", Verb: "list", GVR: secretsGVR, Namespace: "ns", LabelSelector: "app=a,env in (prod),tier=web"},
+		},
+		{
+			name:       "no label selector",
+			attributes: Attributes{Source: "syncer-1", Verb: "watch", GVR: secretsGVR, Namespace: "ns"},
+		},
+		{
+			name:       "invalid label selector",
+			attributes: Attributes{Source: "syncer-1", Verb: "list", GVR: secretsGVR, Namespace: "ns", LabelSelector: "app in a"},
+		},
+		{
+			name:       "label selectors not applied to get",
+			attributes: Attributes{Source: "syncer-1", Verb: "get", GVR: secretsGVR, Namespace: "ns", Name: "a"},
+			allowed:    true,
+		},
+		{
+			name:       "subresource",
+			attributes: Attributes{Source: "agent", Verb: "update", GVR: deploymentsGVR, Subresource: "status", Namespace: "ns", Name: "a"},
+			allowed:    true,
+		},
+		{
+			name:       "resource of the subresource",
+			attributes: Attributes{Source: "agent", Verb: "update", GVR: deploymentsGVR, Namespace: "ns", Name: "a"},
+		},
+		{
+			name:       "other subresource",
+			attributes: Attributes{Source: "agent", Verb: "update", GVR: deploymentsGVR, Subresource: "scale", Namespace: "ns", Name: "a"},
+		},
+		{
+			name:       "any resource and subresource",
+			attributes: Attributes{Source: "admin", Verb: "delete", GVR: deploymentsGVR, Subresource: "status", Namespace: "ns", Name: "a"},
+			allowed:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			allowed, reason := authorizer.Authorize(&c.attributes)
+			if allowed != c.allowed {
+				t.Errorf("expected allowed %v, got %v", c.allowed, allowed)
+			}
+			if !allowed && len(reason) == 0 {
+				t.Errorf("expected the reason of the denied request")
+			}
+		})
+	}
+}
+
+func TestRequestVerb(t *testing.T) {
+	cases := []struct {
+		mode                string
+		subresources        []string
+		expectedVerb        string
+		expectedSubresource string
+	}{
+		{mode: apis.ModeList, expectedVerb: "list"},
+		{mode: apis.ModeWatch, expectedVerb: "watch"},
+		{mode: apis.ModeGet, subresources: []string{"status"}, expectedVerb: "get", expectedSubresource: "status"},
+		{mode: apis.ModeCreate, expectedVerb: "create"},
+		{mode: apis.ModeUpdate, subresources: []string{"scale"}, expectedVerb: "update", expectedSubresource: "scale"},
+		{mode: apis.ModeUpdateStatus, expectedVerb: "update", expectedSubresource: "status"},
+		{mode: apis.ModePatch, expectedVerb: "patch"},
+		{mode: apis.ModeApply, expectedVerb: "patch"},
+		{mode: apis.ModeApply, subresources: []string{"status"}, expectedVerb: "patch", expectedSubresource: "status"},
+		{mode: apis.ModeDelete, expectedVerb: "delete"},
+	}
+
+	for _, c := range cases {
+		verb, subresource := requestVerb(c.mode, c.subresources)
+		if verb != c.expectedVerb || subresource != c.expectedSubresource {
+			t.Errorf("expected %s %v to be %q %q, got %q %q", c.mode, c.subresources, c.expectedVerb, c.expectedSubresource, verb, subresource)
+		}
+	}
+}
+
+func TestLoadRuleAuthorizer(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules string
+		valid bool
+	}{
+		{
+			name: "valid",
+			rules: `rules:
+- sources: ["syncer-*"]
+  verbs: ["list", "watch"]
+  apiGroups: [""]
+  resources: ["secrets"]
+  labelSelectors: ["app=a"]
+`,
+			valid: true,
+		},
+		{
+			name: "no source",
+			rules: `rules:
+- verbs: ["list"]
+  apiGroups: [""]
+  resources: ["secrets"]
+`,
+		},
+		{
+			name: "invalid source pattern",
+			rules: `rules:
+- sources: ["syncer-["]
+  verbs: ["list"]
+  apiGroups: [""]
+  resources: ["secrets"]
+`,
+		},
+		{
+			name: "invalid label selector",
+			rules: `rules:
+- sources: ["syncer"]
+  verbs: ["list"]
+  apiGroups: [""]
+  resources: ["secrets"]
+  labelSelectors: ["app in a"]
+`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.yaml")
+			if err := ioutil.WriteFile(path, []byte(c.rules), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := LoadRuleAuthorizer(path); (err == nil) != c.valid {
+				t.Errorf("expected valid %v, got error %v", c.valid, err)
+			}
+		})
+	}
+}
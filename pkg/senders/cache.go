@@ -0,0 +1,92 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+type watchCacheKey struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+}
+
+type cachedSender struct {
+	Sender
+	ctx    context.Context
+	client dynamic.Interface
+
+	lock   sync.Mutex
+	caches map[watchCacheKey]*watchCache
+}
+
+// NewCachedSender builds a Sender serving the lists and watches from a watch cache of each
+// resource and namespace. A watch cache is fed by one list and watch of the apiserver, and
+// its events are fanned out to all the watchers, so many informers watching a resource cost
+// the apiserver one watch. The lists and watches with a field selector other than the name
+// and the namespace, the lists the cache can not serve as requested, e.g. the lists without
+// resource version or with a continue token, the gets and the writes are sent to the
+// apiserver. The watch caches are started on the first request of the resource and run until
+// the context is done.
+func NewCachedSender(ctx context.Context, client dynamic.Interface) Sender {
+	return &cachedSender{
+		Sender: NewDynamicSender(client),
+		ctx:    ctx,
+		client: client,
+		caches: map[watchCacheKey]*watchCache{},
+	}
+}
+
+func (c *cachedSender) List(namespace string, gvr schema.GroupVersionResource, options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if !listCacheSupported(options) {
+		return c.Sender.List(namespace, gvr, options)
+	}
+	return c.watchCache(namespace, gvr).list(options)
+}
+
+func (c *cachedSender) Watch(namespace string, gvr schema.GroupVersionResource, options metav1.ListOptions) (watch.Interface, error) {
+	if !cacheSupported(options) {
+		return c.Sender.Watch(namespace, gvr, options)
+	}
+	return c.watchCache(namespace, gvr).watch(options)
+}
+
+// watchCache returns the watch cache of the resource in the namespace, the cache is started
+// if it does not exist.
+func (c *cachedSender) watchCache(namespace string, gvr schema.GroupVersionResource) *watchCache {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := watchCacheKey{gvr: gvr, namespace: namespace}
+	if wc, ok := c.caches[key]; ok {
+		return wc
+	}
+
+	wc := newWatchCache(gvr)
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			objectList, err := c.client.Resource(gvr).Namespace(namespace).List(c.ctx, options)
+			if err != nil {
+				return nil, err
+			}
+			wc.setListKind(objectList.GetKind())
+			return objectList, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return c.client.Resource(gvr).Namespace(namespace).Watch(c.ctx, options)
+		},
+	}
+	reflector := cache.NewNamedReflector(fmt.Sprintf("watch cache of %s in namespace %q", gvr, namespace), lw, &unstructured.Unstructured{}, wc, 0)
+	go reflector.Run(c.ctx.Done())
+
+	c.caches[key] = wc
+	return wc
+}
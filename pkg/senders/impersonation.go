@@ -0,0 +1,153 @@
+package senders
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+// Impersonator returns the sender answering the requests of a requester, so the requests are
+// authorized by the apiserver with the identity of the requester.
+type Impersonator interface {
+	Sender(source string) (Sender, error)
+}
+
+// IdentityMappings is the mapping file of the impersonator, a requester is impersonated as the
+// identity of the first mapping matching its source.
+type IdentityMappings struct {
+	Identities []IdentityMapping `json:"identities"`
+}
+
+// IdentityMapping maps the requesters to a kubernetes user and groups.
+type IdentityMapping struct {
+	// Sources are the identities of the requesters matched as the shell patterns, e.g. "syncer-*",
+	// "*" matches any requester.
+	Sources []string `json:"sources"`
+	// User is the user impersonated. The source of the request is the user if it is empty, so
+	// the audit logs show which requester reads what. The source is not authenticated, so the
+	// user is required if any source is a pattern, otherwise a requester could pick any user.
+	User string `json:"user,omitempty"`
+	// Groups are the groups impersonated.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// LoadIdentityMappings reads the identity mappings from a yaml or json file.
+func LoadIdentityMappings(path string) (*IdentityMappings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mappings := &IdentityMappings{}
+	if err := yaml.Unmarshal(data, mappings); err != nil {
+		return nil, fmt.Errorf("failed to parse identity mappings %s: %v", path, err)
+	}
+	if err := mappings.validate(); err != nil {
+		return nil, fmt.Errorf("invalid identity mappings %s: %v", path, err)
+	}
+	return mappings, nil
+}
+
+func (m *IdentityMappings) validate() error {
+	for i, identity := range m.Identities {
+		if len(identity.Sources) == 0 {
+			return fmt.Errorf("identity %d: sources are required", i)
+		}
+		for _, source := range identity.Sources {
+			if _, err := path.Match(source, ""); err != nil {
+				return fmt.Errorf("identity %d: invalid source %q: %v", i, source, err)
+			}
+			if len(identity.User) == 0 && isSourcePattern(source) {
+				return fmt.Errorf("identity %d: user is required for the source pattern %q", i, source)
+			}
+		}
+	}
+	return nil
+}
+
+// isSourcePattern returns true if the source matches more than the source itself.
+func isSourcePattern(source string) bool {
+	return strings.ContainsAny(source, `*?[\`)
+}
+
+// impersonationConfig returns the identity impersonated for the source, false if no mapping
+// matches the source.
+func (m *IdentityMappings) impersonationConfig(source string) (rest.ImpersonationConfig, bool) {
+	for _, identity := range m.Identities {
+		if !matchesSource(identity.Sources, source) {
+			continue
+		}
+
+		config := rest.ImpersonationConfig{
+			UserName: identity.User,
+			Groups:   identity.Groups,
+		}
+		if len(config.UserName) == 0 {
+			config.UserName = source
+		}
+		return config, true
+	}
+	return rest.ImpersonationConfig{}, false
+}
+
+type impersonator struct {
+	config   *rest.Config
+	mappings *IdentityMappings
+
+	lock sync.Mutex
+	// senders are the senders of the impersonated identities, keyed by the user and the groups.
+	senders map[string]Sender
+}
+
+// NewImpersonator builds an Impersonator sending the requests to the apiserver of the config as
+// the identities mapped from the requesters. The credentials of the config must be allowed to
+// impersonate the users and the groups. A requester matching no mapping is forbidden.
+func NewImpersonator(config *rest.Config, mappings *IdentityMappings) (Impersonator, error) {
+	if err := mappings.validate(); err != nil {
+		return nil, err
+	}
+
+	return &impersonator{
+		config:   config,
+		mappings: mappings,
+		senders:  map[string]Sender{},
+	}, nil
+}
+
+func (i *impersonator) Sender(source string) (Sender, error) {
+	impersonate, ok := i.mappings.impersonationConfig(source)
+	if !ok {
+		return nil, apierrors.NewForbidden(schema.GroupResource{}, "", fmt.Errorf("no identity is mapped to the requester %q", source))
+	}
+
+	groups := append([]string{}, impersonate.Groups...)
+	sort.Strings(groups)
+	key := impersonate.UserName + "/" + strings.Join(groups, ",")
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if sender, ok := i.senders[key]; ok {
+		return sender, nil
+	}
+
+	config := rest.CopyConfig(i.config)
+	config.Impersonate = impersonate
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := NewDynamicSender(client)
+	i.senders[key] = sender
+	return sender, nil
+}
@@ -0,0 +1,66 @@
+package senders
+
+import (
+	"testing"
+)
+
+func TestIdentityMappingsValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		identity IdentityMapping
+		valid    bool
+	}{
+		{
+			name:     "exact source without user",
+			identity: IdentityMapping{Sources: []string{"syncer-1"}, Groups: []string{"syncers"}},
+			valid:    true,
+		},
+		{
+			name:     "source pattern with user",
+			identity: IdentityMapping{Sources: []string{"syncer-*"}, User: "syncer"},
+			valid:    true,
+		},
+		{
+			name:     "source pattern without user",
+			identity: IdentityMapping{Sources: []string{"syncer-*"}, Groups: []string{"syncers"}},
+		},
+		{
+			name:     "any source without user",
+			identity: IdentityMapping{Sources: []string{"admin", "*"}},
+		},
+		{
+			name:     "no source",
+			identity: IdentityMapping{User: "syncer"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mappings := &IdentityMappings{Identities: []IdentityMapping{c.identity}}
+			if err := mappings.validate(); (err == nil) != c.valid {
+				t.Errorf("expected valid %v, got error %v", c.valid, err)
+			}
+		})
+	}
+}
+
+func TestImpersonationConfig(t *testing.T) {
+	mappings := &IdentityMappings{Identities: []IdentityMapping{
+		{Sources: []string{"admin"}},
+		{Sources: []string{"syncer-*"}, User: "syncer", Groups: []string{"syncers"}},
+	}}
+
+	config, ok := mappings.impersonationConfig("admin")
+	if !ok || config.UserName != "admin" {
+		t.Errorf("expected the exact source is impersonated as itself, got %v", config)
+	}
+
+	config, ok = mappings.impersonationConfig("syncer-cluster-admin")
+	if !ok || config.UserName != "syncer" {
+		t.Errorf("expected the source matching the pattern is impersonated as the user, got %v", config)
+	}
+
+	if _, ok := mappings.impersonationConfig("other"); ok {
+		t.Errorf("expected no identity for the source matching no mapping")
+	}
+}
@@ -6,12 +6,19 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
 type Sender interface {
 	List(namespace string, gvr schema.GroupVersionResource, options metav1.ListOptions) (*unstructured.UnstructuredList, error)
 	Watch(namespace string, gvr schema.GroupVersionResource, options metav1.ListOptions) (watch.Interface, error)
+	Get(namespace string, gvr schema.GroupVersionResource, name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error)
+	Create(namespace string, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error)
+	Update(namespace string, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error)
+	UpdateStatus(namespace string, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, options metav1.UpdateOptions) (*unstructured.Unstructured, error)
+	Patch(namespace string, gvr schema.GroupVersionResource, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error)
+	Delete(namespace string, gvr schema.GroupVersionResource, name string, options metav1.DeleteOptions, subresources ...string) error
 }
 
 type SenderTransport interface {
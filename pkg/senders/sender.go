@@ -6,6 +6,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 )
@@ -27,3 +28,27 @@ func (d *dynamicSender) List(namespace string, gvr schema.GroupVersionResource,
 func (d *dynamicSender) Watch(namespace string, gvr schema.GroupVersionResource, options metav1.ListOptions) (watch.Interface, error) {
 	return d.client.Resource(gvr).Namespace(namespace).Watch(context.TODO(), options)
 }
+
+func (d *dynamicSender) Get(namespace string, gvr schema.GroupVersionResource, name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return d.client.Resource(gvr).Namespace(namespace).Get(context.TODO(), name, options, subresources...)
+}
+
+func (d *dynamicSender) Create(namespace string, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return d.client.Resource(gvr).Namespace(namespace).Create(context.TODO(), obj, options, subresources...)
+}
+
+func (d *dynamicSender) Update(namespace string, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return d.client.Resource(gvr).Namespace(namespace).Update(context.TODO(), obj, options, subresources...)
+}
+
+func (d *dynamicSender) UpdateStatus(namespace string, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, options metav1.UpdateOptions) (*unstructured.Unstructured, error) {
+	return d.client.Resource(gvr).Namespace(namespace).UpdateStatus(context.TODO(), obj, options)
+}
+
+func (d *dynamicSender) Patch(namespace string, gvr schema.GroupVersionResource, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return d.client.Resource(gvr).Namespace(namespace).Patch(context.TODO(), name, pt, data, options, subresources...)
+}
+
+func (d *dynamicSender) Delete(namespace string, gvr schema.GroupVersionResource, name string, options metav1.DeleteOptions, subresources ...string) error {
+	return d.client.Resource(gvr).Namespace(namespace).Delete(context.TODO(), name, options, subresources...)
+}
@@ -0,0 +1,379 @@
+package senders
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// RedactedValue replaces the string values of the redacted fields.
+const RedactedValue = "REDACTED"
+
+// Transformer transforms the objects before they are sent to the requesters, e.g. to prune
+// the fields not needed by the informers or to keep the sensitive fields on the cluster.
+type Transformer interface {
+	// Transform returns the object sent for the resource. The object might be shared, e.g. by
+	// the watch cache, so it is never changed, a transformed copy is returned instead.
+	Transform(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) *unstructured.Unstructured
+	// Transforms returns true if the objects of the resource might lose fields, so the objects
+	// the requesters hold might not be complete. Dropping the managed fields does not count,
+	// an update without the managed fields keeps them on the cluster.
+	Transforms(gvr schema.GroupVersionResource) bool
+}
+
+// TransformRules is the rule file of the RuleTransformer, all the rules matching a resource are
+// applied in order.
+type TransformRules struct {
+	Rules []TransformRule `json:"rules"`
+}
+
+// TransformRule transforms the objects of the resources. The fields are JSONPaths, e.g.
+// ".metadata.annotations", ".data.*", ".spec.containers[*].env" or
+// ".metadata.labels['app.kubernetes.io/name']". "*" matches all the keys of a map or all the
+// items of a list. In a rule, the managed fields are dropped first, then the fields are kept,
+// removed and redacted.
+type TransformRule struct {
+	// APIGroups are the groups of the resources, "" is the core group. They are required.
+	APIGroups []string `json:"apiGroups"`
+	// Resources are the resources, "*" matches any resource. They are required.
+	Resources []string `json:"resources"`
+	// DropManagedFields drops the managed fields of the objects.
+	DropManagedFields bool `json:"dropManagedFields,omitempty"`
+	// KeepFields are the only fields kept if it is set. The apiVersion, the kind, and the name,
+	// namespace, uid and resource version of the metadata are always kept, the informers
+	// need them to decode and to cache the objects.
+	KeepFields []string `json:"keepFields,omitempty"`
+	// RemoveFields are the fields removed.
+	RemoveFields []string `json:"removeFields,omitempty"`
+	// RemoveDataKeys are the keys removed from the data and the binaryData, e.g. of the secrets
+	// and the configmaps.
+	RemoveDataKeys []string `json:"removeDataKeys,omitempty"`
+	// RedactFields are the fields whose values are redacted. A string is replaced with
+	// RedactedValue, the other values are replaced with their zero values, so the objects
+	// can still be decoded.
+	RedactFields []string `json:"redactFields,omitempty"`
+}
+
+// fieldPath is a parsed JSONPath, each element is a key of a map or "*".
+type fieldPath []string
+
+// alwaysKept are the fields kept by KeepFields.
+var alwaysKept = []fieldPath{
+	{"apiVersion"},
+	{"kind"},
+	{"metadata", "name"},
+	{"metadata", "namespace"},
+	{"metadata", "uid"},
+	{"metadata", "resourceVersion"},
+}
+
+type transformRule struct {
+	apiGroups         []string
+	resources         []string
+	dropManagedFields bool
+	keepFields        []fieldPath
+	removeFields      []fieldPath
+	redactFields      []fieldPath
+}
+
+type ruleTransformer struct {
+	rules []transformRule
+}
+
+// NewRuleTransformer builds a Transformer applying the rules to the objects of the resources
+// they match. The objects of the other resources are sent as they are.
+func NewRuleTransformer(rules *TransformRules) (Transformer, error) {
+	t := &ruleTransformer{}
+	for i, rule := range rules.Rules {
+		if len(rule.APIGroups) == 0 || len(rule.Resources) == 0 {
+			return nil, fmt.Errorf("rule %d: apiGroups and resources are required", i)
+		}
+
+		parsed := transformRule{
+			apiGroups:         rule.APIGroups,
+			resources:         rule.Resources,
+			dropManagedFields: rule.DropManagedFields,
+		}
+
+		var err error
+		if parsed.keepFields, err = parseFieldPaths(rule.KeepFields); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		if len(parsed.keepFields) > 0 {
+			parsed.keepFields = append(parsed.keepFields, alwaysKept...)
+		}
+		if parsed.removeFields, err = parseFieldPaths(rule.RemoveFields); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		for _, key := range rule.RemoveDataKeys {
+			parsed.removeFields = append(parsed.removeFields, fieldPath{"data", key}, fieldPath{"binaryData", key})
+		}
+		if parsed.redactFields, err = parseFieldPaths(rule.RedactFields); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+
+		t.rules = append(t.rules, parsed)
+	}
+	return t, nil
+}
+
+// LoadRuleTransformer reads the rules from a yaml or json file and builds the Transformer.
+func LoadRuleTransformer(path string) (Transformer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &TransformRules{}
+	if err := yaml.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("failed to parse transform rules %s: %v", path, err)
+	}
+	return NewRuleTransformer(rules)
+}
+
+func (t *ruleTransformer) Transforms(gvr schema.GroupVersionResource) bool {
+	for _, rule := range t.rules {
+		if rule.lossy() && matchesValue(rule.apiGroups, gvr.Group) && matchesValue(rule.resources, gvr.Resource) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *ruleTransformer) Transform(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj == nil {
+		return nil
+	}
+
+	var transformed *unstructured.Unstructured
+	for _, rule := range t.rules {
+		if !matchesValue(rule.apiGroups, gvr.Group) || !matchesValue(rule.resources, gvr.Resource) {
+			continue
+		}
+
+		// the object is copied once, the rules then change the copy.
+		if transformed == nil {
+			transformed = obj.DeepCopy()
+		}
+		rule.apply(transformed)
+	}
+
+	if transformed == nil {
+		return obj
+	}
+	return transformed
+}
+
+// lossy returns true if the rule keeps, removes or redacts the fields.
+func (r *transformRule) lossy() bool {
+	return len(r.keepFields) > 0 || len(r.removeFields) > 0 || len(r.redactFields) > 0
+}
+
+func (r *transformRule) apply(obj *unstructured.Unstructured) {
+	if r.dropManagedFields {
+		obj.SetManagedFields(nil)
+	}
+
+	if len(r.keepFields) > 0 {
+		kept, _ := keepFields(obj.Object, r.keepFields).(map[string]interface{})
+		if kept == nil {
+			kept = map[string]interface{}{}
+		}
+		obj.Object = kept
+	}
+
+	for _, path := range r.removeFields {
+		visitField(obj.Object, path, func(parent map[string]interface{}, key string) {
+			delete(parent, key)
+		})
+	}
+
+	for _, path := range r.redactFields {
+		visitField(obj.Object, path, func(parent map[string]interface{}, key string) {
+			parent[key] = redactValue(parent[key])
+		})
+	}
+}
+
+// keepFields returns the value with only the fields of the paths, nil if none of the fields
+// exists. The value is not copied, the kept fields are shared with it.
+func keepFields(value interface{}, paths []fieldPath) interface{} {
+	for _, path := range paths {
+		if len(path) == 0 {
+			return value
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		kept := map[string]interface{}{}
+		for key, field := range v {
+			rest := []fieldPath{}
+			for _, path := range paths {
+				if path[0] == "*" || path[0] == key {
+					rest = append(rest, path[1:])
+				}
+			}
+			if len(rest) == 0 {
+				continue
+			}
+			if keptField := keepFields(field, rest); keptField != nil {
+				kept[key] = keptField
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return kept
+	case []interface{}:
+		rest := []fieldPath{}
+		for _, path := range paths {
+			if path[0] == "*" {
+				rest = append(rest, path[1:])
+			}
+		}
+		if len(rest) == 0 {
+			return nil
+		}
+
+		kept := []interface{}{}
+		for _, item := range v {
+			if keptItem := keepFields(item, rest); keptItem != nil {
+				kept = append(kept, keptItem)
+			}
+		}
+		return kept
+	}
+	return nil
+}
+
+// visitField calls visit with the map containing the field and the key of the field, for each
+// field matched by the path.
+func visitField(value interface{}, path fieldPath, visit func(parent map[string]interface{}, key string)) {
+	if len(path) == 0 {
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if path[0] != "*" {
+			if len(path) == 1 {
+				if _, ok := v[path[0]]; ok {
+					visit(v, path[0])
+				}
+				return
+			}
+			visitField(v[path[0]], path[1:], visit)
+			return
+		}
+
+		for key := range v {
+			if len(path) == 1 {
+				visit(v, key)
+				continue
+			}
+			visitField(v[key], path[1:], visit)
+		}
+	case []interface{}:
+		// the items of a list are matched by "*", a list itself is removed or redacted by
+		// the path ending at its field.
+		if path[0] != "*" || len(path) == 1 {
+			return
+		}
+		for _, item := range v {
+			visitField(item, path[1:], visit)
+		}
+	}
+}
+
+// redactValue returns the redacted value, which keeps the type of the value.
+func redactValue(value interface{}) interface{} {
+	switch value.(type) {
+	case string:
+		return RedactedValue
+	case int64:
+		return int64(0)
+	case float64:
+		return float64(0)
+	case bool:
+		return false
+	case map[string]interface{}:
+		return map[string]interface{}{}
+	case []interface{}:
+		return []interface{}{}
+	}
+	return nil
+}
+
+// parseFieldPaths parses the JSONPaths of the fields, e.g. ".spec.containers[*].image" or
+// "{.metadata.labels['app.kubernetes.io/name']}".
+func parseFieldPaths(paths []string) ([]fieldPath, error) {
+	parsed := []fieldPath{}
+	for _, path := range paths {
+		fields, err := parseFieldPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %q: %v", path, err)
+		}
+		parsed = append(parsed, fields)
+	}
+	return parsed, nil
+}
+
+func parseFieldPath(path string) (fieldPath, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimSuffix(strings.TrimPrefix(p, "{"), "}")
+	p = strings.TrimPrefix(p, "$")
+
+	fields := fieldPath{}
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty field name")
+			}
+			fields = append(fields, p[:end])
+			p = p[end:]
+		case '[':
+			// a quoted key may contain "]", so the closing quote is found first.
+			if len(p) > 1 && (p[1] == '\'' || p[1] == '"') {
+				end := strings.IndexByte(p[2:], p[1])
+				if end < 0 {
+					return nil, fmt.Errorf("unclosed quote")
+				}
+				end += 2
+				if end+1 >= len(p) || p[end+1] != ']' {
+					return nil, fmt.Errorf("unclosed bracket")
+				}
+				fields = append(fields, p[2:end])
+				p = p[end+2:]
+				continue
+			}
+
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket")
+			}
+			if p[1:end] != "*" {
+				return nil, fmt.Errorf("only [*] and quoted keys are supported in brackets")
+			}
+			fields = append(fields, "*")
+			p = p[end+1:]
+		default:
+			return nil, fmt.Errorf("field must start with '.' or '['")
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no field is set")
+	}
+	return fields, nil
+}
@@ -0,0 +1,266 @@
+package senders
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var podsGVR = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func TestParseFieldPath(t *testing.T) {
+	cases := []struct {
+		path     string
+		expected fieldPath
+		invalid  bool
+	}{
+		{path: ".metadata.annotations", expected: fieldPath{"metadata", "annotations"}},
+		{path: "{.data.*}", expected: fieldPath{"data", "*"}},
+		{path: "$.spec.containers[*].env", expected: fieldPath{"spec", "containers", "*", "env"}},
+		{path: ".spec.containers[*].ports[*].name", expected: fieldPath{"spec", "containers", "*", "ports", "*", "name"}},
+		{path: ".metadata.labels['app.kubernetes.io/name']", expected: fieldPath{"metadata", "labels", "app.kubernetes.io/name"}},
+		{path: `.metadata.labels["app.kubernetes.io/name"]`, expected: fieldPath{"metadata", "labels", "app.kubernetes.io/name"}},
+		{path: ".metadata.annotations['a]b'].x", expected: fieldPath{"metadata", "annotations", "a]b", "x"}},
+		{path: `.metadata.annotations["it's"]`, expected: fieldPath{"metadata", "annotations", "it's"}},
+		{path: ".data['a.b'][*]", expected: fieldPath{"data", "a.b", "*"}},
+		{path: "", invalid: true},
+		{path: "metadata", invalid: true},
+		{path: ".metadata..name", invalid: true},
+		{path: ".spec.containers[0]", invalid: true},
+		{path: ".spec.containers[*", invalid: true},
+		{path: ".metadata.labels['app", invalid: true},
+		{path: ".metadata.labels['app'", invalid: true},
+		{path: ".metadata.labels['a']b']", invalid: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			fields, err := parseFieldPath(c.path)
+			if c.invalid {
+				if err == nil {
+					t.Errorf("expected the path is invalid, got %v", fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(fields, c.expected) {
+				t.Errorf("expected %v, got %v", c.expected, fields)
+			}
+		})
+	}
+}
+
+func newPod() map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":            "a",
+			"namespace":       "ns",
+			"uid":             "uid",
+			"resourceVersion": "1",
+			"labels":          map[string]interface{}{"app": "a", "tier": "web"},
+			"annotations":     map[string]interface{}{"a]b": "x", "note": "y"},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name":  "app",
+					"image": "app:v1",
+					"env":   []interface{}{map[string]interface{}{"name": "TOKEN", "value": "t"}},
+					"ports": []interface{}{map[string]interface{}{"name": "http", "containerPort": int64(80)}},
+				},
+				map[string]interface{}{"name": "sidecar", "image": "sidecar:v1"},
+			},
+			"hostNetwork": true,
+		},
+	}
+}
+
+func mustParseFieldPaths(t *testing.T, paths ...string) []fieldPath {
+	parsed, err := parseFieldPaths(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestKeepFields(t *testing.T) {
+	cases := []struct {
+		name     string
+		paths    []string
+		expected interface{}
+	}{
+		{
+			name:  "nested lists",
+			paths: []string{".spec.containers[*].ports[*].name"},
+			expected: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{
+							"ports": []interface{}{map[string]interface{}{"name": "http"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:  "quoted key",
+			paths: []string{".metadata.annotations['a]b']", ".metadata.labels['app']"},
+			expected: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels":      map[string]interface{}{"app": "a"},
+					"annotations": map[string]interface{}{"a]b": "x"},
+				},
+			},
+		},
+		{
+			name:  "all the keys",
+			paths: []string{".metadata.labels.*"},
+			expected: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{"app": "a", "tier": "web"},
+				},
+			},
+		},
+		{
+			name:  "missing field",
+			paths: []string{".status.phase"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kept := keepFields(newPod(), mustParseFieldPaths(t, c.paths...))
+			if c.expected == nil {
+				if kept != nil {
+					t.Errorf("expected nothing is kept, got %v", kept)
+				}
+				return
+			}
+			if !reflect.DeepEqual(kept, c.expected) {
+				t.Errorf("expected %v, got %v", c.expected, kept)
+			}
+		})
+	}
+}
+
+func TestKeepFieldsAlwaysKept(t *testing.T) {
+	transformer, err := NewRuleTransformer(&TransformRules{Rules: []TransformRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, KeepFields: []string{".spec.hostNetwork"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transformed := transformer.Transform(podsGVR, &unstructured.Unstructured{Object: newPod()})
+	expected := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":            "a",
+			"namespace":       "ns",
+			"uid":             "uid",
+			"resourceVersion": "1",
+		},
+		"spec": map[string]interface{}{"hostNetwork": true},
+	}
+	if !reflect.DeepEqual(transformed.Object, expected) {
+		t.Errorf("expected %v, got %v", expected, transformed.Object)
+	}
+}
+
+func TestVisitField(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		expected []string
+	}{
+		{name: "field", path: ".spec.hostNetwork", expected: []string{"hostNetwork"}},
+		{name: "all the keys", path: ".metadata.labels.*", expected: []string{"app", "tier"}},
+		{name: "nested lists", path: ".spec.containers[*].env[*].value", expected: []string{"value"}},
+		{name: "items of a list", path: ".spec.containers[*].image", expected: []string{"image", "image"}},
+		{name: "quoted key", path: ".metadata.annotations['a]b']", expected: []string{"a]b"}},
+		{name: "list itself", path: ".spec.containers", expected: []string{"containers"}},
+		{name: "list not indexed", path: ".spec.containers.image"},
+		{name: "missing field", path: ".status.phase"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			visited := []string{}
+			visitField(newPod(), mustParseFieldPaths(t, c.path)[0], func(parent map[string]interface{}, key string) {
+				if _, ok := parent[key]; !ok {
+					t.Errorf("expected the visited field %q exists", key)
+				}
+				visited = append(visited, key)
+			})
+
+			sort.Strings(visited)
+			if len(visited) != len(c.expected) || (len(visited) > 0 && !reflect.DeepEqual(visited, c.expected)) {
+				t.Errorf("expected the fields %v are visited, got %v", c.expected, visited)
+			}
+		})
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{value: "secret", expected: RedactedValue},
+		{value: int64(80), expected: int64(0)},
+		{value: float64(0.5), expected: float64(0)},
+		{value: true, expected: false},
+		{value: map[string]interface{}{"a": "b"}, expected: map[string]interface{}{}},
+		{value: []interface{}{"a"}, expected: []interface{}{}},
+		{value: nil, expected: nil},
+	}
+
+	for _, c := range cases {
+		if redacted := redactValue(c.value); !reflect.DeepEqual(redacted, c.expected) {
+			t.Errorf("expected %v redacted as %#v, got %#v", c.value, c.expected, redacted)
+		}
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	transformer, err := NewRuleTransformer(&TransformRules{Rules: []TransformRule{
+		{
+			APIGroups:    []string{""},
+			Resources:    []string{"pods"},
+			RedactFields: []string{".spec.containers[*].env", ".spec.containers[*].ports[*].containerPort", ".spec.hostNetwork"},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := &unstructured.Unstructured{Object: newPod()}
+	transformed := transformer.Transform(podsGVR, original)
+
+	containers := transformed.Object["spec"].(map[string]interface{})["containers"].([]interface{})
+	app := containers[0].(map[string]interface{})
+	if env := app["env"]; !reflect.DeepEqual(env, []interface{}{}) {
+		t.Errorf("expected the env is redacted as an empty list, got %#v", env)
+	}
+	if port := app["ports"].([]interface{})[0].(map[string]interface{})["containerPort"]; port != int64(0) {
+		t.Errorf("expected the port is redacted as 0, got %#v", port)
+	}
+	if hostNetwork := transformed.Object["spec"].(map[string]interface{})["hostNetwork"]; hostNetwork != false {
+		t.Errorf("expected the host network is redacted as false, got %#v", hostNetwork)
+	}
+	if _, ok := containers[1].(map[string]interface{})["env"]; ok {
+		t.Errorf("expected no env is added to the container without env")
+	}
+
+	if !reflect.DeepEqual(original.Object, newPod()) {
+		t.Errorf("expected the original object is not changed")
+	}
+}
@@ -3,129 +3,866 @@ package senders
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
 	"github.com/qiujian16/events-informer/pkg/apis"
+	"github.com/qiujian16/events-informer/pkg/encryption"
+	"github.com/qiujian16/events-informer/pkg/signing"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/klog/v2"
 )
 
+// DefaultListChunkSize is the default max number of objects in one list response event.
+const DefaultListChunkSize int64 = 100
+
+// DefaultBookmarkInterval is the default interval of the synthetic bookmarks of a quiet watch.
+const DefaultBookmarkInterval = time.Minute
+
 type defaultSenderTansport struct {
-	sender    Sender
-	sclient   cloudevents.Client
-	rclient   cloudevents.Client
-	watchStop map[types.UID]context.CancelFunc
+	sender        Sender
+	sclient       cloudevents.Client
+	rclient       cloudevents.Client
+	watches       *watchTable
+	listChunkSize int64
+	clusterName   string
+	// bookmarkInterval is the interval of the synthetic bookmarks, they are disabled if it is 0.
+	bookmarkInterval time.Duration
+	// senderID is the instance id of the sender, the responses carry it so the informers tell
+	// that the sender is restarted and the watches are lost.
+	senderID string
+	// authorizer decides whether a request is answered, all the requests are answered if it is nil.
+	authorizer Authorizer
+	// impersonator returns the sender of the requester, the requests are answered by the sender
+	// if it is nil.
+	impersonator Impersonator
+	// signer signs the responses, and verifier verifies the requests if they are set.
+	signer   signing.Signer
+	verifier signing.Verifier
+	// encryptor encrypts the responses of the sensitive resources to their requesters if it is set.
+	encryptor encryption.Encryptor
+	// transformer transforms the objects before they are sent if it is set.
+	transformer Transformer
+}
+
+// SenderTransportOption configures the sender transport.
+type SenderTransportOption func(*defaultSenderTansport)
+
+// WithListChunkSize sets the max number of objects in one list response event. The list
+// response is split into multiple events so each event fits in the message size limit
+// of the transport.
+func WithListChunkSize(size int64) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		if size > 0 {
+			d.listChunkSize = size
+		}
+	}
+}
+
+// WithClusterName sets the cluster of the sender, only the requests addressed to the cluster
+// are answered and the responses are sent from the cluster.
+func WithClusterName(clusterName string) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		d.clusterName = clusterName
+	}
+}
+
+// WithBookmarkInterval sets the interval of the synthetic bookmarks sent on a quiet watch
+// which allows bookmarks, the synthetic bookmarks are disabled if the interval is 0.
+func WithBookmarkInterval(interval time.Duration) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		d.bookmarkInterval = interval
+	}
+}
+
+// WithAuthorizer sets the authorizer of the requests, a denied request is answered with a
+// Forbidden error. Without an authorizer, all the requests are answered with the credentials
+// of the sender.
+func WithAuthorizer(authorizer Authorizer) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		d.authorizer = authorizer
+	}
 }
 
-func NewDefaultSenderTansport(sender Sender, sclient, rclient cloudevents.Client) SenderTransport {
-	return &defaultSenderTansport{
-		sender:    sender,
-		sclient:   sclient,
-		rclient:   rclient,
-		watchStop: map[types.UID]context.CancelFunc{},
+// WithImpersonator sets the impersonator of the requesters, the requests are sent to the
+// apiserver as the identity of the requester instead of the credentials of the sender, so
+// the rbac of the apiserver applies to each requester. The watch cache of the sender is not
+// used for the impersonated requests.
+func WithImpersonator(impersonator Impersonator) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		d.impersonator = impersonator
 	}
 }
 
+// WithSigner signs the responses with the signer, so the informers can verify that the
+// responses are sent by the sender.
+func WithSigner(signer signing.Signer) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		d.signer = signer
+	}
+}
+
+// WithVerifier verifies the requests with the verifier, the requests without a valid
+// signature are dropped.
+func WithVerifier(verifier signing.Verifier) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		d.verifier = verifier
+	}
+}
+
+// WithEncryptor encrypts the responses of the sensitive resources with the encryptor, so only
+// the requester can read the objects. The requesters without a key are forbidden to request
+// the sensitive resources.
+func WithEncryptor(encryptor encryption.Encryptor) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		d.encryptor = encryptor
+	}
+}
+
+// WithTransformer transforms the objects of the list, watch, get and result responses with the
+// transformer, e.g. to prune the fields or to redact the sensitive values before they leave the
+// cluster. The objects are transformed before the responses are encrypted.
+func WithTransformer(transformer Transformer) SenderTransportOption {
+	return func(d *defaultSenderTansport) {
+		d.transformer = transformer
+	}
+}
+
+func NewDefaultSenderTansport(sender Sender, sclient, rclient cloudevents.Client, opts ...SenderTransportOption) SenderTransport {
+	d := &defaultSenderTansport{
+		sender:           sender,
+		sclient:          sclient,
+		rclient:          rclient,
+		watches:          newWatchTable(),
+		listChunkSize:    DefaultListChunkSize,
+		bookmarkInterval: DefaultBookmarkInterval,
+		senderID:         string(uuid.NewUUID()),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// request is a request event received by the sender, the responses are addressed to its source.
+type request struct {
+	id     types.UID
+	source string
+	gvr    schema.GroupVersionResource
+	// sender answers the request, it is the sender impersonating the requester if the
+	// impersonation is enabled.
+	sender Sender
+	*apis.RequestEvent
+}
+
+// newResponse builds the response event of the request.
+func (d *defaultSenderTansport) newResponse(req *request, eventType string, data interface{}) cloudevents.Event {
+	evt := cloudevents.NewEvent()
+	evt.SetID(string(req.id))
+	evt.SetType(eventType)
+	evt.SetSource(d.responseSource())
+	evt.SetExtension(apis.ExtensionRecipient, req.source)
+	evt.SetExtension(apis.ExtensionPartitionKey, string(req.id))
+	d.setSenderExtensions(&evt)
+	evt.SetData(cloudevents.ApplicationJSON, data)
+	return evt
+}
+
+// setSenderExtensions sets the cluster and the instance id of the sender on the event.
+func (d *defaultSenderTansport) setSenderExtensions(evt *cloudevents.Event) {
+	evt.SetExtension(apis.ExtensionSenderID, d.senderID)
+	if len(d.clusterName) > 0 {
+		evt.SetExtension(apis.ExtensionClusterName, d.clusterName)
+	}
+}
+
+// announce tells the informers that the sender is started. The watches on the former
+// instance of the sender are lost, the informers watch again from their last resource
+// versions.
+func (d *defaultSenderTansport) announce(ctx context.Context) {
+	evt := cloudevents.NewEvent()
+	evt.SetID(string(uuid.NewUUID()))
+	evt.SetType(apis.EventSenderStartedType)
+	evt.SetSource(d.responseSource())
+	d.setSenderExtensions(&evt)
+
+	result := d.send(ctx, evt)
+	if cloudevents.IsUndelivered(result) {
+		klog.Errorf("failed to announce the start of the sender with error: %v", result)
+	}
+}
+
+// send signs the event if the signer is set, and sends it.
+func (d *defaultSenderTansport) send(ctx context.Context, evt cloudevents.Event) error {
+	if d.signer != nil {
+		if err := d.signer.Sign(&evt); err != nil {
+			return err
+		}
+	}
+	return d.sclient.Send(ctx, evt)
+}
+
+// responseSource is the source of the responses, it is the cluster name if it is set.
+func (d *defaultSenderTansport) responseSource() string {
+	if len(d.clusterName) > 0 {
+		return d.clusterName
+	}
+	return "server"
+}
+
 func (d *defaultSenderTansport) Run(ctx context.Context) {
+	d.announce(ctx)
+
 	d.rclient.StartReceiver(ctx, func(evt cloudevents.Event) error {
+		// the request is addressed to another cluster
+		if apis.ClusterName(evt) != d.clusterName {
+			return nil
+		}
+
+		if d.verifier != nil {
+			if err := d.verifier.Verify(evt); err != nil {
+				klog.Warningf("drop request %s from %s: %v", evt.ID(), evt.Source(), err)
+				return err
+			}
+		}
+
 		mode, gvr, err := apis.ParseEventType(evt.Type())
 		if err != nil {
 			return err
 		}
 
-		req := &apis.RequestEvent{}
-		err = json.Unmarshal(evt.Data(), &req)
+		req := &request{
+			id:     types.UID(evt.ID()),
+			source: evt.Source(),
+			gvr:    gvr,
+			sender: d.sender,
+		}
+
+		if mode == apis.ModeGet {
+			get := &apis.GetRequestEvent{}
+			if err := json.Unmarshal(evt.Data(), get); err != nil {
+				return err
+			}
+
+			klog.Infof("received get request of %s %s/%s from %s", gvr, get.Namespace, get.Name, req.source)
+			verb, subresource := requestVerb(mode, get.Subresources)
+			if !d.authorize(ctx, req, mode, &Attributes{
+				Verb:        verb,
+				Subresource: subresource,
+				Namespace:   get.Namespace,
+				Name:        get.Name,
+			}) || !d.impersonate(ctx, req, mode) || !d.acceptEncryption(ctx, req, mode) {
+				return nil
+			}
+			return d.sendGetResponse(ctx, req, get)
+		}
+
+		if apis.IsWriteMode(mode) {
+			write := &apis.WriteRequestEvent{}
+			if err := json.Unmarshal(evt.Data(), write); err != nil {
+				return err
+			}
+
+			klog.Infof("received %s request of %s %s/%s from %s", mode, gvr, write.Namespace, write.Name, req.source)
+			verb, subresource := requestVerb(mode, write.Subresources)
+			if !d.authorize(ctx, req, mode, &Attributes{
+				Verb:        verb,
+				Subresource: subresource,
+				Namespace:   write.Namespace,
+				Name:        write.Name,
+			}) || !d.impersonate(ctx, req, mode) || !d.acceptEncryption(ctx, req, mode) || !d.acceptWrite(ctx, req, mode) {
+				return nil
+			}
+			return d.sendResultResponse(ctx, req, mode, write)
+		}
+
+		req.RequestEvent = &apis.RequestEvent{}
+		err = json.Unmarshal(evt.Data(), req.RequestEvent)
 		if err != nil {
 			return err
 		}
 
-		klog.Infof("received request of %v", req)
+		klog.Infof("received request of %v from %s", req.RequestEvent, req.source)
+
+		// stopping a watch is not authorized, the watch is authorized when it is started and is
+		// only stopped by its requester.
+		if mode != apis.ModeStopWatch && (!d.authorize(ctx, req, mode, &Attributes{
+			Verb:          mode,
+			Namespace:     req.Namespace,
+			LabelSelector: req.Options.LabelSelector,
+			FieldSelector: req.Options.FieldSelector,
+		}) || !d.impersonate(ctx, req, mode) || !d.acceptEncryption(ctx, req, mode)) {
+			return nil
+		}
 
 		switch mode {
-		case "list":
-			return d.sendListResponses(ctx, types.UID(evt.ID()), req.Namespace, gvr, req.Options)
-		case "watch":
-			go d.watchResponse(ctx, types.UID(evt.ID()), req.Namespace, gvr, req.Options)
-		case "stopwatch":
-			cancelFunc, ok := d.watchStop[types.UID(evt.ID())]
-			if ok {
-				cancelFunc()
-				delete(d.watchStop, types.UID(evt.ID()))
+		case apis.ModeList:
+			return d.sendListResponses(ctx, req)
+		case apis.ModeWatch:
+			// register the stop func before starting the watch, so a stop request arriving
+			// right after the watch request is not missed.
+			watchCtx, ok := d.watches.add(ctx, req.id, req.source)
+			if !ok {
+				return nil
+			}
+			go d.watchResponse(watchCtx, req)
+		case apis.ModeStopWatch:
+			if !d.watches.stop(req.WatchID, req.source) {
+				klog.Warningf("refused to stop watch %s of another requester from %s", req.WatchID, req.source)
 			}
 		}
 		return nil
 	})
 }
 
-func (d *defaultSenderTansport) watchResponse(ctx context.Context, id types.UID, namespace string, gvr schema.GroupVersionResource, options metav1.ListOptions) error {
-	w, err := d.sender.Watch(namespace, gvr, options)
+// authorize evaluates the request with the authorizer. A denied request is answered with a
+// Forbidden error, a denied watch with an error event ending the watch.
+func (d *defaultSenderTansport) authorize(ctx context.Context, req *request, mode string, attributes *Attributes) bool {
+	if d.authorizer == nil {
+		return true
+	}
+
+	attributes.Source = req.source
+	attributes.GVR = req.gvr
+	allowed, reason := d.authorizer.Authorize(attributes)
+	if allowed {
+		return true
+	}
+
+	klog.Infof("denied %s request of %s from %s: %s", mode, req.gvr, req.source, reason)
+	d.reject(ctx, req, mode, apierrors.NewForbidden(req.gvr.GroupResource(), attributes.Name, errors.New(reason)))
+	return false
+}
+
+// impersonate sets the sender impersonating the requester on the request. The request is
+// answered with the error if the requester can not be impersonated.
+func (d *defaultSenderTansport) impersonate(ctx context.Context, req *request, mode string) bool {
+	if d.impersonator == nil {
+		return true
+	}
+
+	sender, err := d.impersonator.Sender(req.source)
 	if err != nil {
-		return err
+		klog.Errorf("failed to impersonate %s with err: %v", req.source, err)
+		d.reject(ctx, req, mode, err)
+		return false
+	}
+
+	req.sender = sender
+	return true
+}
+
+// acceptEncryption checks that the responses of the request can be encrypted to the requester.
+// The request is answered with a Forbidden error if the requester has no encryption key.
+func (d *defaultSenderTansport) acceptEncryption(ctx context.Context, req *request, mode string) bool {
+	if d.encryptor == nil {
+		return true
 	}
 
-	watchCtx, stop := context.WithCancel(ctx)
-	d.watchStop[id] = stop
+	if err := d.encryptor.Accepts(req.gvr, req.source); err != nil {
+		klog.Infof("denied %s request of %s from %s: %v", mode, req.gvr, req.source, err)
+		d.reject(ctx, req, mode, apierrors.NewForbidden(req.gvr.GroupResource(), "", err))
+		return false
+	}
+	return true
+}
+
+// acceptWrite refuses the updates and the applies of the transformed resources. The objects
+// the requesters hold are pruned or redacted, writing them back would drop or overwrite the
+// fields on the cluster, so only the patches are allowed.
+func (d *defaultSenderTansport) acceptWrite(ctx context.Context, req *request, mode string) bool {
+	if d.transformer == nil || !d.transformer.Transforms(req.gvr) {
+		return true
+	}
+
+	switch mode {
+	case apis.ModeUpdate, apis.ModeUpdateStatus, apis.ModeApply:
+		klog.Infof("denied %s request of transformed %s from %s", mode, req.gvr, req.source)
+		d.reject(ctx, req, mode, apierrors.NewMethodNotSupported(req.gvr.GroupResource(), mode))
+		return false
+	}
+	return true
+}
+
+// encrypt encrypts the data of the response to the requester if the encryptor is set.
+func (d *defaultSenderTansport) encrypt(req *request, evt *cloudevents.Event) error {
+	if d.encryptor == nil {
+		return nil
+	}
+	return d.encryptor.Encrypt(evt, req.gvr, req.source)
+}
+
+// transform transforms the object sent to the requester if the transformer is set.
+func (d *defaultSenderTansport) transform(req *request, obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if d.transformer == nil {
+		return obj
+	}
+	return d.transformer.Transform(req.gvr, obj)
+}
+
+// transformList transforms the items of the list, the list is not changed since its items
+// might be shared, e.g. by the watch cache.
+func (d *defaultSenderTansport) transformList(req *request, list *unstructured.UnstructuredList) *unstructured.UnstructuredList {
+	if d.transformer == nil {
+		return list
+	}
+
+	transformed := &unstructured.UnstructuredList{Object: list.Object}
+	for i := range list.Items {
+		transformed.Items = append(transformed.Items, *d.transform(req, &list.Items[i]))
+	}
+	return transformed
+}
+
+// reject answers the request with the error, a watch is answered with an error event ending
+// the watch.
+func (d *defaultSenderTansport) reject(ctx context.Context, req *request, mode string, err error) {
+	if mode == apis.ModeWatch {
+		d.sendWatchResponse(ctx, req, errorWatchResponse(err))
+		return
+	}
+	d.sendErrorResponse(ctx, req, err)
+}
+
+// watchResponse forwards the watch events to the watcher until the watch is stopped. If the
+// watch fails or is closed by the apiserver, the watcher is notified with the end of watch.
+// The bookmarks are always requested from the apiserver to track the resource version of the
+// watch, they are forwarded only if the watcher allows bookmarks. A synthetic bookmark is sent
+// if the watch is quiet for the bookmark interval, so the watcher keeps a fresh resource version.
+func (d *defaultSenderTansport) watchResponse(ctx context.Context, req *request) {
+	defer d.watches.end(req.id)
+
+	// the events of the watch are numbered, so the watcher handles them in order.
+	sequence := int64(0)
+	send := func(response *apis.WatchResponseEvent) {
+		sequence++
+		response.Sequence = sequence
+		d.sendWatchResponse(ctx, req, response)
+	}
+
+	options := req.Options
+	options.AllowWatchBookmarks = true
+	w, err := req.sender.Watch(req.Namespace, req.gvr, options)
+	if err != nil {
+		klog.Errorf("failed to watch resource %v with err: %v", req.gvr, err)
+		send(errorWatchResponse(err))
+		return
+	}
 	defer w.Stop()
 
+	// acknowledge the watch, so the watcher knows the instance of the sender serving it.
+	send(&apis.WatchResponseEvent{})
+
+	bookmarks := newWatchBookmarks(req)
+	var bookmarkCh <-chan time.Time
+	if req.Options.AllowWatchBookmarks && d.bookmarkInterval > 0 {
+		ticker := time.NewTicker(d.bookmarkInterval)
+		defer ticker.Stop()
+		bookmarkCh = ticker.C
+	}
+
 	for {
 		select {
 		case e, ok := <-w.ResultChan():
 			if !ok {
-				return fmt.Errorf("failed to watch the result")
+				send(&apis.WatchResponseEvent{EndOfWatch: true})
+				return
+			}
+
+			obj, err := toUnstructured(e.Object)
+			if err != nil {
+				klog.Errorf("failed to convert watch event of resource %v with err: %v", req.gvr, err)
+				send(errorWatchResponse(err))
+				return
+			}
+
+			if e.Type != watch.Error {
+				bookmarks.observe(obj)
+			}
+
+			if e.Type == watch.Bookmark && !req.Options.AllowWatchBookmarks {
+				continue
+			}
+
+			// the bookmarks and the error events carry no fields of the objects.
+			if e.Type != watch.Bookmark && e.Type != watch.Error {
+				obj = d.transform(req, obj)
 			}
 
-			response := &apis.WatchResponseEvent{
+			send(&apis.WatchResponseEvent{
 				Type:   e.Type,
-				Object: e.Object.(*unstructured.Unstructured),
+				Object: obj,
+			})
+			bookmarks.sent()
+		case <-bookmarkCh:
+			bookmark := bookmarks.next(d.bookmarkInterval)
+			if bookmark == nil {
+				continue
 			}
 
-			evt := cloudevents.NewEvent()
-			evt.SetID(string(id))
-			evt.SetType(apis.EventWatchResponseType(gvr))
-			evt.SetSource("server")
-			evt.SetData(cloudevents.ApplicationJSON, response)
+			send(&apis.WatchResponseEvent{
+				Type:   watch.Bookmark,
+				Object: bookmark,
+			})
+			bookmarks.sent()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchBookmarks tracks the resource version of a watch and when an event is last sent, to
+// build the synthetic bookmarks of the quiet watch.
+type watchBookmarks struct {
+	apiVersion      string
+	kind            string
+	resourceVersion string
+	lastSent        time.Time
+}
 
-			klog.Infof("send watch response for resource %v", gvr)
-			result := d.sclient.Send(ctx, evt)
+func newWatchBookmarks(req *request) *watchBookmarks {
+	b := &watchBookmarks{
+		apiVersion: req.gvr.GroupVersion().String(),
+		lastSent:   time.Now(),
+	}
 
-			if result != nil {
-				klog.Errorf(result.Error())
+	// "0" means any resource version, it is not a position of the watch.
+	if req.Options.ResourceVersion != "0" {
+		b.resourceVersion = req.Options.ResourceVersion
+	}
+	return b
+}
+
+func (b *watchBookmarks) observe(obj *unstructured.Unstructured) {
+	if len(obj.GetResourceVersion()) > 0 {
+		b.resourceVersion = obj.GetResourceVersion()
+	}
+	if len(obj.GetKind()) > 0 {
+		b.apiVersion, b.kind = obj.GetAPIVersion(), obj.GetKind()
+	}
+}
+
+func (b *watchBookmarks) sent() {
+	b.lastSent = time.Now()
+}
+
+// next returns the synthetic bookmark if no event is sent for the interval, or nil if the
+// resource version or the kind of the watch is not known yet. The kind is learnt from the
+// events, e.g. the bookmarks of the apiserver, an object without kind can not be decoded.
+func (b *watchBookmarks) next(interval time.Duration) *unstructured.Unstructured {
+	if len(b.resourceVersion) == 0 || len(b.kind) == 0 || time.Since(b.lastSent) < interval {
+		return nil
+	}
+
+	bookmark := &unstructured.Unstructured{}
+	bookmark.SetAPIVersion(b.apiVersion)
+	bookmark.SetKind(b.kind)
+	bookmark.SetResourceVersion(b.resourceVersion)
+	return bookmark
+}
+
+func (d *defaultSenderTansport) sendWatchResponse(ctx context.Context, req *request, response *apis.WatchResponseEvent) {
+	evt := d.newResponse(req, apis.EventWatchResponseType(req.gvr), response)
+	// an error event carries a status instead of an object, it is not encrypted so a requester
+	// without an encryption key is told why the watch is denied.
+	if response.Type != watch.Error {
+		if err := d.encrypt(req, &evt); err != nil {
+			klog.Errorf("failed to encrypt watch response with error: %v", err)
+			return
+		}
+	}
+
+	klog.Infof("send watch response for resource %v", req.gvr)
+	result := d.send(ctx, evt)
+
+	if cloudevents.IsUndelivered(result) {
+		klog.Errorf(result.Error())
+	}
+}
+
+// sendListResponses pages through the objects with limit and continue, and sends them
+// in chunks. The limit of the request is the max number of objects in the whole list
+// response, if it is reached the continue token is returned in the last chunk.
+func (d *defaultSenderTansport) sendListResponses(ctx context.Context, req *request) error {
+	options := req.Options
+	remaining := options.Limit
+	pageOptions := options
+	index := 0
+	for {
+		pageOptions.Limit = d.listChunkSize
+		if remaining > 0 && remaining < pageOptions.Limit {
+			pageOptions.Limit = remaining
+		}
+
+		objs, err := req.sender.List(req.Namespace, req.gvr, pageOptions)
+		if err != nil {
+			klog.Errorf("failed to list resource with err: %v", err)
+			d.sendErrorResponse(ctx, req, err)
+			return err
+		}
+
+		remaining -= int64(len(objs.Items))
+		endOfList := len(objs.GetContinue()) == 0 || (options.Limit > 0 && remaining <= 0)
+
+		// the sender might not respect the limit, e.g. a list served from a cache, so the page
+		// is split into chunks as well.
+		chunks := splitList(d.transformList(req, objs), d.listChunkSize)
+		for i, chunk := range chunks {
+			response := &apis.ListResponseEvent{
+				Objects:   chunk,
+				Index:     index,
+				EndOfList: endOfList && i == len(chunks)-1,
+			}
+			if err := d.sendListResponse(ctx, req, response); err != nil {
+				return err
 			}
-		case <-watchCtx.Done():
+			index++
+		}
+
+		if endOfList {
 			return nil
 		}
+
+		// resource version is not allowed when continue is set
+		pageOptions.Continue = objs.GetContinue()
+		pageOptions.ResourceVersion = ""
+		pageOptions.ResourceVersionMatch = ""
 	}
 }
 
-func (d *defaultSenderTansport) sendListResponses(ctx context.Context, id types.UID, namespace string, gvr schema.GroupVersionResource, options metav1.ListOptions) error {
-	objs, err := d.sender.List(namespace, gvr, options)
-	if err != nil {
-		klog.Errorf("failed to list resource with err: %v", err)
+func (d *defaultSenderTansport) sendListResponse(ctx context.Context, req *request, response *apis.ListResponseEvent) error {
+	evt := d.newResponse(req, apis.EventListResponseType(req.gvr), response)
+	if err := d.encrypt(req, &evt); err != nil {
+		klog.Errorf("failed to encrypt list response with error: %v", err)
 		return err
 	}
 
-	response := &apis.ListResponseEvent{
-		Objects:   objs,
-		EndOfList: true,
+	klog.Infof("send list response chunk %d for resource %v", response.Index, req.gvr)
+	result := d.send(ctx, evt)
+
+	if cloudevents.IsUndelivered(result) {
+		klog.Errorf("failed to send list response with error: %v", result)
+		return fmt.Errorf(result.Error())
 	}
 
-	evt := cloudevents.NewEvent()
-	evt.SetID(string(id))
-	evt.SetType(apis.EventListResponseType(gvr))
-	evt.SetSource("server")
-	evt.SetData(cloudevents.ApplicationJSON, response)
+	return nil
+}
+
+// sendGetResponse gets the object and sends it, or the NotFound status if the object does
+// not exist. Other failures are sent as the error response.
+func (d *defaultSenderTansport) sendGetResponse(ctx context.Context, req *request, get *apis.GetRequestEvent) error {
+	response := &apis.GetResponseEvent{}
+
+	obj, err := req.sender.Get(get.Namespace, req.gvr, get.Name, get.Options, get.Subresources...)
+	switch {
+	case apierrors.IsNotFound(err):
+		status := statusFromError(err)
+		response.Status = &status
+	case err != nil:
+		klog.Errorf("failed to get resource %v with err: %v", req.gvr, err)
+		d.sendErrorResponse(ctx, req, err)
+		return err
+	default:
+		response.Object = d.transform(req, obj)
+	}
+
+	evt := d.newResponse(req, apis.EventGetResponseType(req.gvr), response)
+	if err := d.encrypt(req, &evt); err != nil {
+		klog.Errorf("failed to encrypt get response with error: %v", err)
+		return err
+	}
 
-	klog.Infof("send list response for resource %v", gvr)
-	result := d.sclient.Send(ctx, evt)
+	klog.Infof("send get response for resource %v", req.gvr)
+	result := d.send(ctx, evt)
 
-	if result != nil {
-		klog.Errorf("failed to send request with error: %v", err)
+	if cloudevents.IsUndelivered(result) {
+		klog.Errorf("failed to send get response with error: %v", result)
 		return fmt.Errorf(result.Error())
 	}
 
 	return nil
 }
+
+// sendResultResponse executes the write request and sends the result, or the error if
+// the write is failed.
+func (d *defaultSenderTansport) sendResultResponse(ctx context.Context, req *request, mode string, write *apis.WriteRequestEvent) error {
+	obj, err := d.write(req.sender, req.gvr, mode, write)
+	if err != nil {
+		klog.Errorf("failed to %s resource %v with err: %v", mode, req.gvr, err)
+		d.sendErrorResponse(ctx, req, err)
+		return err
+	}
+
+	evt := d.newResponse(req, apis.EventResultResponseType(req.gvr), &apis.ResultResponseEvent{Object: d.transform(req, obj)})
+	if err := d.encrypt(req, &evt); err != nil {
+		klog.Errorf("failed to encrypt result response with error: %v", err)
+		return err
+	}
+
+	klog.Infof("send result response for resource %v", req.gvr)
+	result := d.send(ctx, evt)
+
+	if cloudevents.IsUndelivered(result) {
+		klog.Errorf("failed to send result response with error: %v", result)
+		return fmt.Errorf(result.Error())
+	}
+
+	return nil
+}
+
+// write executes the write request with the sender. An apply request is executed as a
+// patch with the apply patch type.
+func (d *defaultSenderTansport) write(sender Sender, gvr schema.GroupVersionResource, mode string, write *apis.WriteRequestEvent) (*unstructured.Unstructured, error) {
+	switch mode {
+	case apis.ModeCreate, apis.ModeUpdate, apis.ModeUpdateStatus, apis.ModeApply:
+		if write.Object == nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("object is required to %s %s", mode, gvr))
+		}
+	}
+
+	switch mode {
+	case apis.ModeCreate:
+		options := metav1.CreateOptions{}
+		if write.CreateOptions != nil {
+			options = *write.CreateOptions
+		}
+		return sender.Create(write.Namespace, gvr, write.Object, options, write.Subresources...)
+	case apis.ModeUpdate:
+		options := metav1.UpdateOptions{}
+		if write.UpdateOptions != nil {
+			options = *write.UpdateOptions
+		}
+		return sender.Update(write.Namespace, gvr, write.Object, options, write.Subresources...)
+	case apis.ModeUpdateStatus:
+		// the status is written back based on an object received by the requester, the
+		// resource version is required so the update is conflicted if the object is changed since.
+		if len(write.Object.GetResourceVersion()) == 0 {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("resourceVersion is required to %s %s", mode, gvr))
+		}
+		options := metav1.UpdateOptions{}
+		if write.UpdateOptions != nil {
+			options = *write.UpdateOptions
+		}
+		return sender.UpdateStatus(write.Namespace, gvr, write.Object, options)
+	case apis.ModePatch:
+		options := metav1.PatchOptions{}
+		if write.PatchOptions != nil {
+			options = *write.PatchOptions
+		}
+		return sender.Patch(write.Namespace, gvr, write.Name, write.PatchType, write.Patch, options, write.Subresources...)
+	case apis.ModeApply:
+		options := metav1.ApplyOptions{}
+		if write.ApplyOptions != nil {
+			options = *write.ApplyOptions
+		}
+		data, err := json.Marshal(write.Object)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		return sender.Patch(write.Namespace, gvr, write.Name, types.ApplyPatchType, data, options.ToPatchOptions(), write.Subresources...)
+	case apis.ModeDelete:
+		options := metav1.DeleteOptions{}
+		if write.DeleteOptions != nil {
+			options = *write.DeleteOptions
+		}
+		return nil, sender.Delete(write.Namespace, gvr, write.Name, options, write.Subresources...)
+	}
+
+	return nil, apierrors.NewBadRequest(fmt.Sprintf("unsupported write mode %q", mode))
+}
+
+// sendErrorResponse notifies the requester that the request is failed, so the requester
+// does not wait for the response until timeout.
+func (d *defaultSenderTansport) sendErrorResponse(ctx context.Context, req *request, err error) {
+	response := &apis.ErrorResponseEvent{
+		Status: statusFromError(err),
+	}
+
+	evt := d.newResponse(req, apis.EventErrorResponseType(req.gvr), response)
+
+	klog.Infof("send error response for resource %v", req.gvr)
+	result := d.send(ctx, evt)
+
+	if cloudevents.IsUndelivered(result) {
+		klog.Errorf("failed to send error response with error: %v", result)
+	}
+}
+
+// splitList splits the items of a list into chunks with at most size items. Each chunk
+// keeps the metadata of the list. An empty list results in one empty chunk.
+func splitList(list *unstructured.UnstructuredList, size int64) []*unstructured.UnstructuredList {
+	chunks := []*unstructured.UnstructuredList{}
+	for start := 0; start == 0 || start < len(list.Items); start += int(size) {
+		end := start + int(size)
+		if end > len(list.Items) {
+			end = len(list.Items)
+		}
+
+		chunks = append(chunks, &unstructured.UnstructuredList{
+			Object: list.Object,
+			Items:  list.Items[start:end],
+		})
+	}
+	return chunks
+}
+
+// errorWatchResponse builds the error event ending a watch. The error is converted to
+// a metav1.Status, so the watcher can tell e.g. 410 Gone and list again.
+func errorWatchResponse(err error) *apis.WatchResponseEvent {
+	status := statusFromError(err)
+	obj, convertErr := toUnstructured(&status)
+	if convertErr != nil {
+		klog.Errorf("failed to convert status with err: %v", convertErr)
+	}
+
+	return &apis.WatchResponseEvent{
+		Type:       watch.Error,
+		Object:     obj,
+		EndOfWatch: true,
+	}
+}
+
+// statusFromError converts an error to metav1.Status, the status of an api error is kept,
+// e.g. forbidden or not found, other errors are converted to an internal error.
+func statusFromError(err error) metav1.Status {
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) {
+		return apiStatus.Status()
+	}
+	return apierrors.NewInternalError(err).ErrStatus
+}
+
+// toUnstructured converts an object in a watch event to unstructured, e.g. the
+// metav1.Status of an error event.
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if obj == nil {
+		return nil, fmt.Errorf("object is empty")
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unstructured.Unstructured{Object: content}
+	if _, ok := obj.(*metav1.Status); ok {
+		u.SetAPIVersion("v1")
+		u.SetKind("Status")
+	}
+	return u, nil
+}
@@ -0,0 +1,222 @@
+package senders_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/qiujian16/events-informer/pkg/encryption"
+	"github.com/qiujian16/events-informer/pkg/informers"
+	"github.com/qiujian16/events-informer/pkg/senders"
+	eitesting "github.com/qiujian16/events-informer/pkg/testing"
+	"github.com/qiujian16/events-informer/pkg/transport"
+	"github.com/qiujian16/events-informer/pkg/transport/loopback"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TestWatchRestartsDoNotLeak restarts a watch many times, the sender must stop the watches
+// on the apiserver, so no goroutine is left behind.
+func TestWatchRestartsDoNotLeak(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := eitesting.NewFakeClient(eitesting.NewSecret("ns", "a"))
+	bus := loopback.NewBus(loopback.Options{})
+	if err := eitesting.StartLoopbackSender(ctx, bus, client); err != nil {
+		t.Fatal(err)
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := informers.NewEventDynamicClient(ctx, sender, receiver).Resource(eitesting.SecretsGVR).Namespace("ns")
+
+	restart := func() {
+		w, err := secrets.Watch(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the watch is running on the sender once it forwards an event, the objects are updated
+		// until the watch is started.
+		timeout := time.After(5 * time.Second)
+		for received := false; !received; {
+			secret, err := client.Resource(eitesting.SecretsGVR).Namespace("ns").Get(ctx, "a", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			secret.SetLabels(map[string]string{"generation": time.Now().String()})
+			if _, err := client.Resource(eitesting.SecretsGVR).Namespace("ns").Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-w.ResultChan():
+				received = true
+			case <-time.After(100 * time.Millisecond):
+			case <-timeout:
+				t.Fatal("timeout waiting for the watch event")
+			}
+		}
+
+		w.Stop()
+	}
+
+	// the first restart starts the receive loops and the other long running goroutines.
+	restart()
+	baseline := waitForGoroutines(runtime.NumGoroutine())
+
+	for i := 0; i < 20; i++ {
+		restart()
+	}
+
+	if leaked := waitForGoroutines(baseline) - baseline; leaked > 0 {
+		t.Errorf("%d goroutines are leaked by 20 watch restarts", leaked)
+	}
+}
+
+// waitForGoroutines waits until the number of the goroutines is not more than the expected
+// number, and returns the number of the goroutines.
+func waitForGoroutines(expected int) int {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		current := runtime.NumGoroutine()
+		if current <= expected || time.Now().After(deadline) {
+			return current
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// TestTransformedResourceWrites writes a transformed resource, the updates and the applies
+// are refused since the objects of the requesters are not complete, the patches are allowed.
+func TestTransformedResourceWrites(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transformer, err := senders.NewRuleTransformer(&senders.TransformRules{Rules: []senders.TransformRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, RedactFields: []string{".data.*"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := eitesting.NewFakeClient(eitesting.NewSecret("ns", "a"))
+	bus := loopback.NewBus(loopback.Options{})
+	if err := eitesting.StartLoopbackSender(ctx, bus, client, senders.WithTransformer(transformer)); err != nil {
+		t.Fatal(err)
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := informers.NewEventDynamicClient(ctx, sender, receiver).Resource(eitesting.SecretsGVR).Namespace("ns").(informers.EventResourceInterface)
+
+	if _, err := secrets.Update(ctx, eitesting.NewSecret("ns", "a"), metav1.UpdateOptions{}); !apierrors.IsMethodNotSupported(err) {
+		t.Errorf("expected the update is refused, got %v", err)
+	}
+	if _, err := secrets.UpdateStatus(ctx, eitesting.NewSecret("ns", "a"), metav1.UpdateOptions{}); !apierrors.IsMethodNotSupported(err) {
+		t.Errorf("expected the update of the status is refused, got %v", err)
+	}
+	if _, err := secrets.Apply(ctx, "a", eitesting.NewSecret("ns", "a"), metav1.ApplyOptions{FieldManager: "test"}); !apierrors.IsMethodNotSupported(err) {
+		t.Errorf("expected the apply is refused, got %v", err)
+	}
+
+	patched, err := secrets.Patch(ctx, "a", types.MergePatchType, []byte(`{"metadata":{"labels":{"patched":"true"}}}`), metav1.PatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.GetLabels()["patched"] != "true" {
+		t.Errorf("expected the secret is patched, got labels %v", patched.GetLabels())
+	}
+}
+
+// TestEncryptedResponses requests an encrypted resource with and without a recipient key, the
+// requester without a key is forbidden.
+func TestEncryptedResponses(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptor := encryption.NewEncryptor([]schema.GroupResource{eitesting.SecretsGVR.GroupResource()},
+		map[string]*rsa.PublicKey{"client": &key.PublicKey})
+
+	client := eitesting.NewFakeClient(eitesting.NewSecret("ns", "a"))
+	bus := loopback.NewBus(loopback.Options{})
+	if err := eitesting.StartLoopbackSender(ctx, bus, client, senders.WithEncryptor(encryptor)); err != nil {
+		t.Fatal(err)
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := informers.NewEventDynamicClient(ctx, sender, receiver,
+		informers.WithClientID("client"), informers.WithDecryptor(encryption.NewDecryptor("client", key))).Resource(eitesting.SecretsGVR).Namespace("ns")
+	secret, err := secrets.Get(ctx, "a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.GetName() != "a" {
+		t.Errorf("expected the decrypted secret a, got %q", secret.GetName())
+	}
+
+	strangerSecrets := informers.NewEventDynamicClient(ctx, sender, receiver, informers.WithClientID("stranger")).Resource(eitesting.SecretsGVR).Namespace("ns")
+	if _, err := strangerSecrets.Get(ctx, "a", metav1.GetOptions{}); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the get of the requester without a key is forbidden, got %v", err)
+	}
+	if _, err := strangerSecrets.List(ctx, metav1.ListOptions{}); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the list of the requester without a key is forbidden, got %v", err)
+	}
+}
+
+// TestDroppedManagedFieldsWrites updates a resource whose managed fields are dropped, the
+// objects are complete otherwise, so the updates are allowed.
+func TestDroppedManagedFieldsWrites(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	transformer, err := senders.NewRuleTransformer(&senders.TransformRules{Rules: []senders.TransformRule{
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, DropManagedFields: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transformer.Transforms(eitesting.SecretsGVR) {
+		t.Errorf("expected dropping the managed fields does not transform the secrets")
+	}
+
+	client := eitesting.NewFakeClient(eitesting.NewSecret("ns", "a"))
+	bus := loopback.NewBus(loopback.Options{})
+	if err := eitesting.StartLoopbackSender(ctx, bus, client, senders.WithTransformer(transformer)); err != nil {
+		t.Fatal(err)
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := informers.NewEventDynamicClient(ctx, sender, receiver).Resource(eitesting.SecretsGVR).Namespace("ns")
+
+	secret := eitesting.NewSecret("ns", "a")
+	secret.SetLabels(map[string]string{"updated": "true"})
+	updated, err := secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetLabels()["updated"] != "true" {
+		t.Errorf("expected the secret is updated, got labels %v", updated.GetLabels())
+	}
+}
@@ -0,0 +1,501 @@
+package senders
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// watchCacheSize is the number of the recent events kept to serve the watches from a
+	// resource version in the past.
+	watchCacheSize = 1000
+	// cacheWatcherSize is the number of the events buffered for a watcher, a watcher falling
+	// behind is ended and watches again from its last resource version.
+	cacheWatcherSize = 100
+	// cacheSyncTimeout is the timeout of waiting for the watch cache to be synced.
+	cacheSyncTimeout = time.Minute
+	// cacheFreshTimeout is the timeout of waiting for the watch cache to reach the resource
+	// version of a list.
+	cacheFreshTimeout = 3 * time.Second
+)
+
+// cacheEvent is an event kept in the watch cache. The previous object of a modified event
+// tells the watchers with selectors if the object is added to or deleted from their view.
+type cacheEvent struct {
+	eventType       watch.EventType
+	object          *unstructured.Unstructured
+	prevObject      *unstructured.Unstructured
+	resourceVersion uint64
+}
+
+// watchCache is the cache.Store of the reflector of a resource. It keeps the objects and the
+// recent events, serves the lists from the objects and fans the events out to the watchers.
+// The objects are shared by all the lists and watchers, they must not be mutated.
+type watchCache struct {
+	gvr schema.GroupVersionResource
+
+	lock  sync.RWMutex
+	store cache.Store
+	// events is a ring buffer of the recent events, startResourceVersion is the resource
+	// version which the events are after, a watch from an older resource version is expired.
+	events               []*cacheEvent
+	startResourceVersion uint64
+	resourceVersion      string
+	// resourceVersionChanged is closed and replaced when the resource version changes.
+	resourceVersionChanged chan struct{}
+	kind                   string
+	watchers               map[int]*cacheWatcher
+	nextWatcherID          int
+
+	syncOnce sync.Once
+	synced   chan struct{}
+}
+
+var _ cache.Store = &watchCache{}
+var _ cache.ResourceVersionUpdater = &watchCache{}
+
+func newWatchCache(gvr schema.GroupVersionResource) *watchCache {
+	return &watchCache{
+		gvr:      gvr,
+		store:    cache.NewStore(cache.MetaNamespaceKeyFunc),
+		watchers: map[int]*cacheWatcher{},
+		synced:   make(chan struct{}),
+
+		resourceVersionChanged: make(chan struct{}),
+	}
+}
+
+func (c *watchCache) Add(obj interface{}) error {
+	return c.processEvent(watch.Added, obj)
+}
+
+func (c *watchCache) Update(obj interface{}) error {
+	return c.processEvent(watch.Modified, obj)
+}
+
+func (c *watchCache) Delete(obj interface{}) error {
+	return c.processEvent(watch.Deleted, obj)
+}
+
+func (c *watchCache) List() []interface{} {
+	return c.store.List()
+}
+
+func (c *watchCache) ListKeys() []string {
+	return c.store.ListKeys()
+}
+
+func (c *watchCache) Get(obj interface{}) (interface{}, bool, error) {
+	return c.store.Get(obj)
+}
+
+func (c *watchCache) GetByKey(key string) (interface{}, bool, error) {
+	return c.store.GetByKey(key)
+}
+
+// Replace resets the cache with the list of the reflector. The events are not continuous
+// with the list, so the watchers are ended and the recent events are dropped, the watchers
+// watching again from an older resource version list again.
+func (c *watchCache) Replace(list []interface{}, resourceVersion string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.store.Replace(list, resourceVersion); err != nil {
+		return err
+	}
+
+	c.events = nil
+	c.startResourceVersion = parseResourceVersion(resourceVersion)
+	c.setResourceVersion(resourceVersion)
+	for _, obj := range list {
+		if object, ok := obj.(*unstructured.Unstructured); ok {
+			c.kind = object.GetKind()
+			break
+		}
+	}
+	for id, watcher := range c.watchers {
+		watcher.closeResult()
+		delete(c.watchers, id)
+	}
+
+	c.syncOnce.Do(func() {
+		close(c.synced)
+	})
+	return nil
+}
+
+func (c *watchCache) Resync() error {
+	return nil
+}
+
+// UpdateResourceVersion is called by the reflector on the bookmarks, the bookmark is sent to
+// the watchers allowing bookmarks. The reflector calls it after every event as well, so the
+// bookmark is sent only if the resource version moves past the one of the last event.
+func (c *watchCache) UpdateResourceVersion(resourceVersion string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if resourceVersion == c.resourceVersion {
+		return
+	}
+	if rv := parseResourceVersion(resourceVersion); rv > 0 && rv <= parseResourceVersion(c.resourceVersion) {
+		return
+	}
+
+	c.setResourceVersion(resourceVersion)
+	if len(c.kind) == 0 {
+		return
+	}
+
+	bookmark := &unstructured.Unstructured{}
+	bookmark.SetAPIVersion(c.gvr.GroupVersion().String())
+	bookmark.SetKind(c.kind)
+	bookmark.SetResourceVersion(resourceVersion)
+
+	for id, watcher := range c.watchers {
+		if watcher.allowBookmarks && !watcher.add(watch.Event{Type: watch.Bookmark, Object: bookmark}) {
+			watcher.closeResult()
+			delete(c.watchers, id)
+		}
+	}
+}
+
+func (c *watchCache) processEvent(eventType watch.EventType, obj interface{}) error {
+	object, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object %T in the watch cache of %s", obj, c.gvr)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	event := &cacheEvent{
+		eventType:       eventType,
+		object:          object,
+		resourceVersion: parseResourceVersion(object.GetResourceVersion()),
+	}
+	if prev, exists, err := c.store.Get(object); err == nil && exists {
+		event.prevObject, _ = prev.(*unstructured.Unstructured)
+	}
+
+	var err error
+	switch eventType {
+	case watch.Added:
+		err = c.store.Add(object)
+	case watch.Modified:
+		err = c.store.Update(object)
+	case watch.Deleted:
+		err = c.store.Delete(object)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(c.events) >= watchCacheSize {
+		c.startResourceVersion = c.events[0].resourceVersion
+		c.events = c.events[1:]
+	}
+	c.events = append(c.events, event)
+	c.setResourceVersion(object.GetResourceVersion())
+	c.kind = object.GetKind()
+
+	for id, watcher := range c.watchers {
+		watchEvent, ok := watcher.convert(event)
+		if !ok {
+			continue
+		}
+		// the watcher falling behind is ended, it watches again from its last resource version.
+		if !watcher.add(watchEvent) {
+			watcher.closeResult()
+			delete(c.watchers, id)
+		}
+	}
+	return nil
+}
+
+// setResourceVersion sets the resource version of the cache and wakes up the lists waiting
+// for it, the lock must be held.
+func (c *watchCache) setResourceVersion(resourceVersion string) {
+	c.resourceVersion = resourceVersion
+	close(c.resourceVersionChanged)
+	c.resourceVersionChanged = make(chan struct{})
+}
+
+// setListKind sets the kind of the resource from the kind of a list of the apiserver, so the
+// kind is known even if the resource has no object.
+func (c *watchCache) setListKind(listKind string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.kind) == 0 {
+		c.kind = strings.TrimSuffix(listKind, "List")
+	}
+}
+
+// waitUntilFresh waits until the resource version of the cache is not older than the
+// resource version, it fails with a timeout if the cache does not catch up in time.
+func (c *watchCache) waitUntilFresh(resourceVersion uint64) error {
+	timeout := time.NewTimer(cacheFreshTimeout)
+	defer timeout.Stop()
+
+	for {
+		c.lock.RLock()
+		current, changed := parseResourceVersion(c.resourceVersion), c.resourceVersionChanged
+		c.lock.RUnlock()
+		if current >= resourceVersion {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-timeout.C:
+			return apierrors.NewTimeoutError(fmt.Sprintf("too large resource version: %d, current: %d", resourceVersion, current), 1)
+		}
+	}
+}
+
+// waitForSync waits until the cache is filled by the first list of the reflector.
+func (c *watchCache) waitForSync() error {
+	select {
+	case <-c.synced:
+		return nil
+	case <-time.After(cacheSyncTimeout):
+		return apierrors.NewTimeoutError(fmt.Sprintf("timeout waiting for the watch cache of %s to be synced", c.gvr), 0)
+	}
+}
+
+// list lists the objects matching the selectors of the options, the list carries the
+// current resource version of the cache. A list from a resource version waits until the cache
+// is not older than the resource version. The limit of the options is not respected.
+func (c *watchCache) list(options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if err := c.waitForSync(); err != nil {
+		return nil, err
+	}
+
+	if len(options.ResourceVersion) > 0 && options.ResourceVersion != "0" {
+		resourceVersion, err := strconv.ParseUint(options.ResourceVersion, 10, 64)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q", options.ResourceVersion))
+		}
+		if err := c.waitUntilFresh(resourceVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	filter, err := newCacheFilter(options)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	// the list is decoded as unstructured on the informer, so it must have a kind.
+	objectList := &unstructured.UnstructuredList{}
+	objectList.SetAPIVersion(c.gvr.GroupVersion().String())
+	objectList.SetKind(c.kind + "List")
+	objectList.SetResourceVersion(c.resourceVersion)
+	for _, obj := range c.store.List() {
+		object := obj.(*unstructured.Unstructured)
+		if filter(object) {
+			objectList.Items = append(objectList.Items, *object)
+		}
+	}
+	return objectList, nil
+}
+
+// watch starts a watcher of the cache. A watch from an empty resource version or "0" starts
+// with the objects as added events. A watch from a resource version starts with the recent
+// events after the resource version, or fails as expired if the events are dropped.
+func (c *watchCache) watch(options metav1.ListOptions) (watch.Interface, error) {
+	if err := c.waitForSync(); err != nil {
+		return nil, err
+	}
+
+	filter, err := newCacheFilter(options)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	watcher := &cacheWatcher{
+		filter:         filter,
+		allowBookmarks: options.AllowWatchBookmarks,
+	}
+
+	initEvents := []watch.Event{}
+	switch options.ResourceVersion {
+	case "", "0":
+		for _, obj := range c.store.List() {
+			object := obj.(*unstructured.Unstructured)
+			if filter(object) {
+				initEvents = append(initEvents, watch.Event{Type: watch.Added, Object: object})
+			}
+		}
+	default:
+		resourceVersion, err := strconv.ParseUint(options.ResourceVersion, 10, 64)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q", options.ResourceVersion))
+		}
+		if resourceVersion < c.startResourceVersion {
+			return nil, apierrors.NewResourceExpired(fmt.Sprintf("too old resource version: %d (%d)", resourceVersion, c.startResourceVersion))
+		}
+
+		for _, event := range c.events {
+			if event.resourceVersion <= resourceVersion {
+				continue
+			}
+			if watchEvent, ok := watcher.convert(event); ok {
+				initEvents = append(initEvents, watchEvent)
+			}
+		}
+	}
+
+	watcher.result = make(chan watch.Event, len(initEvents)+cacheWatcherSize)
+	for _, event := range initEvents {
+		watcher.result <- event
+	}
+
+	id := c.nextWatcherID
+	c.nextWatcherID++
+	c.watchers[id] = watcher
+	watcher.stop = func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		delete(c.watchers, id)
+		watcher.closeResult()
+	}
+
+	return watcher, nil
+}
+
+func parseResourceVersion(resourceVersion string) uint64 {
+	rv, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return rv
+}
+
+// newCacheFilter builds the filter of the label and field selectors of the options. Only
+// the fields metadata.name and metadata.namespace are supported by the cache.
+func newCacheFilter(options metav1.ListOptions) (func(*unstructured.Unstructured) bool, error) {
+	labelSelector, err := labels.Parse(options.LabelSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	fieldSelector, err := fields.ParseSelector(options.FieldSelector)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+
+	return func(obj *unstructured.Unstructured) bool {
+		return labelSelector.Matches(labels.Set(obj.GetLabels())) &&
+			fieldSelector.Matches(fields.Set{
+				"metadata.name":      obj.GetName(),
+				"metadata.namespace": obj.GetNamespace(),
+			})
+	}, nil
+}
+
+// listCacheSupported returns true if the list can be served by the watch cache. As by the
+// watch cache of the apiserver, the consistent lists, i.e. without resource version, the
+// lists continuing a previous list and the paged lists from a resource version are sent to
+// the apiserver.
+func listCacheSupported(options metav1.ListOptions) bool {
+	if len(options.ResourceVersion) == 0 || len(options.Continue) > 0 {
+		return false
+	}
+	if options.Limit > 0 && options.ResourceVersion != "0" {
+		return false
+	}
+	return cacheSupported(options)
+}
+
+// cacheSupported returns true if the list or watch can be served by the watch cache.
+func cacheSupported(options metav1.ListOptions) bool {
+	if options.ResourceVersionMatch == metav1.ResourceVersionMatchExact {
+		return false
+	}
+
+	fieldSelector, err := fields.ParseSelector(options.FieldSelector)
+	if err != nil {
+		return false
+	}
+	for _, requirement := range fieldSelector.Requirements() {
+		if requirement.Field != "metadata.name" && requirement.Field != "metadata.namespace" {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheWatcher is a watcher of the watch cache. The events are sent by the cache with its
+// lock held, and the result chan is closed with the lock held as well.
+type cacheWatcher struct {
+	result         chan watch.Event
+	filter         func(*unstructured.Unstructured) bool
+	allowBookmarks bool
+	stop           func()
+	closed         bool
+}
+
+func (w *cacheWatcher) ResultChan() <-chan watch.Event {
+	return w.result
+}
+
+func (w *cacheWatcher) Stop() {
+	w.stop()
+}
+
+// add sends the event without blocking, it returns false if the watcher falls behind.
+func (w *cacheWatcher) add(event watch.Event) bool {
+	select {
+	case w.result <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *cacheWatcher) closeResult() {
+	if !w.closed {
+		w.closed = true
+		close(w.result)
+	}
+}
+
+// convert converts the cache event to the event in the view of the watcher. An object
+// modified into the selectors is added, and an object modified out of them is deleted.
+func (w *cacheWatcher) convert(event *cacheEvent) (watch.Event, bool) {
+	current := w.filter(event.object)
+	previous := event.prevObject != nil && w.filter(event.prevObject)
+
+	switch {
+	case event.eventType == watch.Modified && current && previous:
+		return watch.Event{Type: watch.Modified, Object: event.object}, true
+	case event.eventType == watch.Modified && current:
+		return watch.Event{Type: watch.Added, Object: event.object}, true
+	case event.eventType == watch.Modified && previous:
+		return watch.Event{Type: watch.Deleted, Object: event.object}, true
+	case event.eventType != watch.Modified && current:
+		return watch.Event{Type: event.eventType, Object: event.object}, true
+	}
+	return watch.Event{}, false
+}
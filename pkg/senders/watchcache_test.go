@@ -0,0 +1,139 @@
+package senders
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func newCachedSecret(name, resourceVersion string) *unstructured.Unstructured {
+	secret := &unstructured.Unstructured{}
+	secret.SetAPIVersion("v1")
+	secret.SetKind("Secret")
+	secret.SetNamespace("ns")
+	secret.SetName(name)
+	secret.SetResourceVersion(resourceVersion)
+	return secret
+}
+
+func TestWatchCacheBookmarks(t *testing.T) {
+	c := newWatchCache(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	if err := c.Replace([]interface{}{newCachedSecret("a", "1")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := c.watch(metav1.ListOptions{ResourceVersion: "1", AllowWatchBookmarks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// the reflector updates the resource version after every event.
+	if err := c.Add(newCachedSecret("b", "2")); err != nil {
+		t.Fatal(err)
+	}
+	c.UpdateResourceVersion("2")
+	// a bookmark older than the last event.
+	c.UpdateResourceVersion("1")
+	// a bookmark moving past the last event.
+	c.UpdateResourceVersion("5")
+
+	expected := []struct {
+		eventType       watch.EventType
+		resourceVersion string
+	}{
+		{eventType: watch.Added, resourceVersion: "2"},
+		{eventType: watch.Bookmark, resourceVersion: "5"},
+	}
+	for _, e := range expected {
+		event := <-w.ResultChan()
+		if rv := event.Object.(*unstructured.Unstructured).GetResourceVersion(); event.Type != e.eventType || rv != e.resourceVersion {
+			t.Errorf("expected %s event of resource version %s, got %s event of %s", e.eventType, e.resourceVersion, event.Type, rv)
+		}
+	}
+
+	select {
+	case event := <-w.ResultChan():
+		t.Errorf("unexpected %s event", event.Type)
+	default:
+	}
+}
+
+func TestWatchCacheListWaitsUntilFresh(t *testing.T) {
+	c := newWatchCache(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	if err := c.Replace([]interface{}{newCachedSecret("a", "1")}, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	listed := make(chan *unstructured.UnstructuredList)
+	go func() {
+		objectList, err := c.list(metav1.ListOptions{ResourceVersion: "2"})
+		if err != nil {
+			t.Error(err)
+			objectList = &unstructured.UnstructuredList{}
+		}
+		listed <- objectList
+	}()
+
+	if err := c.Add(newCachedSecret("b", "2")); err != nil {
+		t.Fatal(err)
+	}
+	if objectList := <-listed; objectList.GetResourceVersion() != "2" || len(objectList.Items) != 2 {
+		t.Errorf("expected 2 secrets of resource version 2, got %d of %s", len(objectList.Items), objectList.GetResourceVersion())
+	}
+
+	if _, err := c.list(metav1.ListOptions{ResourceVersion: "5"}); !apierrors.IsTimeout(err) {
+		t.Errorf("expected timeout error of a list from a resource version newer than the cache, got %v", err)
+	}
+}
+
+func TestListCacheSupported(t *testing.T) {
+	cases := []struct {
+		name     string
+		options  metav1.ListOptions
+		expected bool
+	}{
+		{name: "any resource version", options: metav1.ListOptions{ResourceVersion: "0"}, expected: true},
+		{name: "paged from any resource version", options: metav1.ListOptions{ResourceVersion: "0", Limit: 10}, expected: true},
+		{name: "not older than resource version", options: metav1.ListOptions{ResourceVersion: "5"}, expected: true},
+		{name: "consistent", options: metav1.ListOptions{}},
+		{name: "continue", options: metav1.ListOptions{ResourceVersion: "0", Continue: "token"}},
+		{name: "paged from resource version", options: metav1.ListOptions{ResourceVersion: "5", Limit: 10}},
+		{name: "exact resource version", options: metav1.ListOptions{ResourceVersion: "5", ResourceVersionMatch: metav1.ResourceVersionMatchExact}},
+		{name: "name selector", options: metav1.ListOptions{ResourceVersion: "0", FieldSelector: "metadata.name=a"}, expected: true},
+		{name: "field selector", options: metav1.ListOptions{ResourceVersion: "0", FieldSelector: "type=Opaque"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if supported := listCacheSupported(c.options); supported != c.expected {
+				t.Errorf("expected list cache supported %v, got %v", c.expected, supported)
+			}
+		})
+	}
+}
+
+// TestCachedSenderEmptyList lists a resource without object from the cache, the list kind
+// is the one of the apiserver.
+func TestCachedSenderEmptyList(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "SecretList"})
+
+	objectList, err := NewCachedSender(ctx, client).List("ns", gvr, metav1.ListOptions{ResourceVersion: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if objectList.GetKind() != "SecretList" {
+		t.Errorf("expected kind SecretList, got %q", objectList.GetKind())
+	}
+}
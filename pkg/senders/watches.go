@@ -0,0 +1,106 @@
+package senders
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// tombstoneTTL is how long the id of a stopped watch is remembered. The requests are handled
+// concurrently, so a stop request might be handled before its watch request, the watch is
+// refused if it arrives within the ttl.
+const tombstoneTTL = 10 * time.Minute
+
+// watchKey is a watch of a requester, the requester is the source of the watch request.
+type watchKey struct {
+	id     types.UID
+	source string
+}
+
+// runningWatch is a running watch and the requester which owns it.
+type runningWatch struct {
+	cancel context.CancelFunc
+	source string
+}
+
+// watchTable tracks the running watches keyed by the id of the watch request. It is safe
+// to add and stop the watches concurrently. A watch is only stopped by its requester.
+type watchTable struct {
+	lock    sync.Mutex
+	watches map[types.UID]*runningWatch
+	// tombstones are the stopped watches and when they are stopped.
+	tombstones map[watchKey]time.Time
+}
+
+func newWatchTable() *watchTable {
+	return &watchTable{
+		watches:    map[types.UID]*runningWatch{},
+		tombstones: map[watchKey]time.Time{},
+	}
+}
+
+// add registers a watch of the requester and returns its context, which is cancelled when the
+// watch is stopped. It returns false if a watch with the id is already running, or the watch
+// of the requester is stopped, e.g. the watch request is delivered more than once, or its
+// stop request is handled before it.
+func (t *watchTable) add(ctx context.Context, id types.UID, source string) (context.Context, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.watches[id]; ok {
+		return nil, false
+	}
+	if _, ok := t.tombstones[watchKey{id: id, source: source}]; ok {
+		return nil, false
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	t.watches[id] = &runningWatch{cancel: cancel, source: source}
+	return watchCtx, true
+}
+
+// stop cancels the watch with the id if it is owned by the requester, and removes it from
+// the table. It returns false if the watch is owned by another requester. The stopped watch
+// is remembered, so the watch is not started if its request is handled after the stop.
+func (t *watchTable) stop(id types.UID, source string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if w, ok := t.watches[id]; ok {
+		if w.source != source {
+			return false
+		}
+		w.cancel()
+		delete(t.watches, id)
+	}
+
+	t.addTombstone(watchKey{id: id, source: source})
+	return true
+}
+
+// end removes the watch with the id when it is ended by the sender, e.g. the watch is closed
+// by the apiserver, whoever the requester is.
+func (t *watchTable) end(id types.UID) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	w, ok := t.watches[id]
+	if !ok {
+		return
+	}
+	w.cancel()
+	delete(t.watches, id)
+	t.addTombstone(watchKey{id: id, source: w.source})
+}
+
+func (t *watchTable) addTombstone(key watchKey) {
+	now := time.Now()
+	for tombstone, stopped := range t.tombstones {
+		if now.Sub(stopped) > tombstoneTTL {
+			delete(t.tombstones, tombstone)
+		}
+	}
+	t.tombstones[key] = now
+}
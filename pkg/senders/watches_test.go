@@ -0,0 +1,122 @@
+package senders
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestWatchTable(t *testing.T) {
+	ctx := context.Background()
+
+	cases := []struct {
+		name    string
+		prepare func(table *watchTable, id types.UID)
+		added   bool
+	}{
+		{
+			name:    "new watch",
+			prepare: func(table *watchTable, id types.UID) {},
+			added:   true,
+		},
+		{
+			name: "duplicated watch",
+			prepare: func(table *watchTable, id types.UID) {
+				table.add(ctx, id, "client")
+			},
+		},
+		{
+			name: "stopped watch",
+			prepare: func(table *watchTable, id types.UID) {
+				table.add(ctx, id, "client")
+				table.stop(id, "client")
+			},
+		},
+		{
+			name: "stop before watch",
+			prepare: func(table *watchTable, id types.UID) {
+				table.stop(id, "client")
+			},
+		},
+		{
+			name: "ended watch",
+			prepare: func(table *watchTable, id types.UID) {
+				table.add(ctx, id, "client")
+				table.end(id)
+			},
+		},
+		{
+			name: "stop of another requester before watch",
+			prepare: func(table *watchTable, id types.UID) {
+				table.stop(id, "other")
+			},
+			added: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			table := newWatchTable()
+			c.prepare(table, "watch")
+
+			_, added := table.add(ctx, "watch", "client")
+			if added != c.added {
+				t.Errorf("expected added %v, got %v", c.added, added)
+			}
+		})
+	}
+}
+
+func TestWatchTableStopCancelsWatch(t *testing.T) {
+	table := newWatchTable()
+	watchCtx, _ := table.add(context.Background(), "watch", "client")
+
+	if table.stop("watch", "other") {
+		t.Errorf("expected the watch is not stopped by another requester")
+	}
+	if watchCtx.Err() != nil {
+		t.Errorf("expected the context of the watch is not cancelled by another requester")
+	}
+
+	if !table.stop("watch", "client") {
+		t.Errorf("expected the watch is stopped by its requester")
+	}
+	select {
+	case <-watchCtx.Done():
+	default:
+		t.Errorf("expected the context of the stopped watch is cancelled")
+	}
+}
+
+func TestWatchTableConcurrently(t *testing.T) {
+	table := newWatchTable()
+
+	wg := sync.WaitGroup{}
+	contexts := make(chan context.Context, 100)
+	for i := 0; i < 100; i++ {
+		id := types.UID(fmt.Sprintf("watch-%d", i))
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if watchCtx, ok := table.add(context.Background(), id, "client"); ok {
+				contexts <- watchCtx
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			table.stop(id, "client")
+		}()
+	}
+	wg.Wait()
+	close(contexts)
+
+	// a watch is either refused or cancelled, whichever of the watch and the stop is first.
+	for watchCtx := range contexts {
+		if watchCtx.Err() == nil {
+			t.Errorf("expected the stopped watch is cancelled")
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the configuration of the signing of the events. The events sent are signed with
+// the signing key, and the events received are verified with the verification keys.
+type Config struct {
+	// SigningKey signs the events sent, the events are not signed if it is not set.
+	SigningKey *KeyConfig `json:"signingKey,omitempty"`
+	// VerificationKeys verify the events received, the events are not verified if it is empty.
+	VerificationKeys []KeyConfig `json:"verificationKeys,omitempty"`
+}
+
+// KeyConfig is a key of the signing. The key file of hmac-sha256 is the shared secret, the key
+// file of ed25519 is a PEM encoded PKCS #8 private key to sign or a PKIX public key to verify.
+type KeyConfig struct {
+	// KeyID is the id of the key carried by the signed events.
+	KeyID string `json:"keyID"`
+	// Algorithm is hmac-sha256 or ed25519.
+	Algorithm string `json:"algorithm"`
+	KeyFile   string `json:"keyFile"`
+	// Sources are the sources of the events which a verification key may sign, matched as the
+	// shell patterns, e.g. "syncer-*". They are required for a verification key, "*" allows
+	// the key to sign for any source.
+	Sources []string `json:"sources,omitempty"`
+}
+
+// LoadConfig reads the signing configuration from a yaml or json file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse signing config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// Load reads the signing configuration from a file and builds its Signer and Verifier, either
+// of them is nil if it is not configured.
+func Load(path string) (Signer, Verifier, error) {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, err := config.Signer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	verifier, err := config.Verifier()
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer, verifier, nil
+}
+
+// Signer builds the Signer of the signing key, it is nil if no signing key is set.
+func (c *Config) Signer() (Signer, error) {
+	if c.SigningKey == nil {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(c.SigningKey.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.SigningKey.Algorithm {
+	case AlgorithmHMACSHA256:
+		return NewHMACSigner(c.SigningKey.KeyID, bytes.TrimSpace(data)), nil
+	case AlgorithmEd25519:
+		key, err := parsePEM(data, x509.ParsePKCS8PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key %s: %v", c.SigningKey.KeyFile, err)
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s is not an ed25519 private key", c.SigningKey.KeyFile)
+		}
+		return NewEd25519Signer(c.SigningKey.KeyID, privateKey), nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %q", c.SigningKey.Algorithm)
+}
+
+// Verifier builds the Verifier of the verification keys, it is nil if no verification key is set.
+func (c *Config) Verifier() (Verifier, error) {
+	if len(c.VerificationKeys) == 0 {
+		return nil, nil
+	}
+
+	keys := map[string]VerificationKey{}
+	sources := map[string][]string{}
+	for _, keyConfig := range c.VerificationKeys {
+		if len(keyConfig.Sources) == 0 {
+			return nil, fmt.Errorf("sources of verification key %q are required", keyConfig.KeyID)
+		}
+		for _, source := range keyConfig.Sources {
+			if _, err := path.Match(source, ""); err != nil {
+				return nil, fmt.Errorf("invalid source %q of verification key %q: %v", source, keyConfig.KeyID, err)
+			}
+		}
+		sources[keyConfig.KeyID] = keyConfig.Sources
+
+		data, err := ioutil.ReadFile(keyConfig.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		switch keyConfig.Algorithm {
+		case AlgorithmHMACSHA256:
+			keys[keyConfig.KeyID] = HMACVerificationKey(bytes.TrimSpace(data))
+		case AlgorithmEd25519:
+			key, err := parsePEM(data, x509.ParsePKIXPublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse verification key %s: %v", keyConfig.KeyFile, err)
+			}
+			publicKey, ok := key.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("verification key %s is not an ed25519 public key", keyConfig.KeyFile)
+			}
+			keys[keyConfig.KeyID] = Ed25519VerificationKey(publicKey)
+		default:
+			return nil, fmt.Errorf("unsupported signing algorithm %q of key %q", keyConfig.Algorithm, keyConfig.KeyID)
+		}
+	}
+	return NewVerifier(keys, sources), nil
+}
+
+func parsePEM(data []byte, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block is found")
+	}
+	return parse(block.Bytes)
+}
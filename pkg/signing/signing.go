@@ -0,0 +1,276 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"expvar"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/apis"
+)
+
+const (
+	AlgorithmHMACSHA256 = "hmac-sha256"
+	AlgorithmEd25519    = "ed25519"
+)
+
+// The reasons of the rejected events.
+const (
+	ReasonUnsigned   = "unsigned"
+	ReasonUnknownKey = "unknown_key"
+	ReasonInvalid    = "invalid_signature"
+	// ReasonUnauthorizedSource is the reason of an event signed with a key which may not sign
+	// for the source of the event.
+	ReasonUnauthorizedSource = "unauthorized_source"
+	// ReasonExpired is the reason of a write request whose time is out of the replay window.
+	ReasonExpired = "expired"
+	// ReasonReplayed is the reason of a write request whose id is already verified.
+	ReasonReplayed = "replayed"
+)
+
+// replayWindow is how far the time of a write request may be from the time it is verified. The
+// ids of the verified write requests are remembered until their time is out of the window, so
+// a write request is accepted only once.
+const replayWindow = 5 * time.Minute
+
+var (
+	// verifiedEvents is the number of the events with a valid signature.
+	verifiedEvents = expvar.NewInt("events_informer_signature_verified_total")
+	// rejectedEvents is the number of the rejected events by the reason.
+	rejectedEvents = expvar.NewMap("events_informer_signature_rejected_total")
+)
+
+// Signer signs the events, the signature is carried in the signature extension of the event.
+type Signer interface {
+	Sign(evt *cloudevents.Event) error
+}
+
+// Verifier verifies the signature of the events, an event without a valid signature is
+// rejected with an error.
+type Verifier interface {
+	Verify(evt cloudevents.Event) error
+}
+
+// RejectedError is the error of an event rejected by the Verifier.
+type RejectedError struct {
+	Reason string
+	KeyID  string
+}
+
+func (e *RejectedError) Error() string {
+	if len(e.KeyID) == 0 {
+		return fmt.Sprintf("event is rejected: %s", e.Reason)
+	}
+	return fmt.Sprintf("event signed with key %q is rejected: %s", e.KeyID, e.Reason)
+}
+
+// IsRejected returns true if the error is the rejection of an event by the Verifier.
+func IsRejected(err error) bool {
+	var rejected *RejectedError
+	return errors.As(err, &rejected)
+}
+
+// content returns the signed content of the event: the id, the type, the source, the time, the
+// extensions routing the event, the key id, the content type and the data.
+func content(evt cloudevents.Event) []byte {
+	timestamp := ""
+	if !evt.Time().IsZero() {
+		timestamp = evt.Time().UTC().Format(time.RFC3339Nano)
+	}
+
+	fields := []string{
+		evt.ID(),
+		evt.Type(),
+		evt.Source(),
+		timestamp,
+		apis.Recipient(evt),
+		apis.ClusterName(evt),
+		apis.SenderID(evt),
+		apis.SignatureKey(evt),
+		evt.DataContentType(),
+	}
+
+	// each field is prefixed with its length, so the fields can not be shifted into each other.
+	buf := []byte{}
+	length := make([]byte, 8)
+	for _, field := range append(fields, string(evt.Data())) {
+		binary.BigEndian.PutUint64(length, uint64(len(field)))
+		buf = append(buf, length...)
+		buf = append(buf, field...)
+	}
+	return buf
+}
+
+type hmacSigner struct {
+	keyID  string
+	secret []byte
+}
+
+// NewHMACSigner builds a Signer signing the events with HMAC-SHA256 of the shared secret.
+func NewHMACSigner(keyID string, secret []byte) Signer {
+	return &hmacSigner{keyID: keyID, secret: secret}
+}
+
+func (s *hmacSigner) Sign(evt *cloudevents.Event) error {
+	setTime(evt)
+	evt.SetExtension(apis.ExtensionSignatureKey, s.keyID)
+	evt.SetExtension(apis.ExtensionSignature, base64.StdEncoding.EncodeToString(hmacSum(s.secret, content(*evt))))
+	return nil
+}
+
+// setTime sets the time of the event to now if it is not set, the time is signed so the
+// cloudevents client must not set it after the signing.
+func setTime(evt *cloudevents.Event) {
+	if evt.Time().IsZero() {
+		evt.SetTime(time.Now())
+	}
+}
+
+func hmacSum(secret, content []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(content)
+	return mac.Sum(nil)
+}
+
+type ed25519Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// NewEd25519Signer builds a Signer signing the events with the ed25519 private key.
+func NewEd25519Signer(keyID string, key ed25519.PrivateKey) Signer {
+	return &ed25519Signer{keyID: keyID, key: key}
+}
+
+func (s *ed25519Signer) Sign(evt *cloudevents.Event) error {
+	setTime(evt)
+	evt.SetExtension(apis.ExtensionSignatureKey, s.keyID)
+	evt.SetExtension(apis.ExtensionSignature, base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, content(*evt))))
+	return nil
+}
+
+// VerificationKey verifies the signatures made with a key.
+type VerificationKey interface {
+	verify(content, signature []byte) bool
+}
+
+type hmacKey []byte
+
+// HMACVerificationKey is the key verifying the signatures of NewHMACSigner with the shared secret.
+func HMACVerificationKey(secret []byte) VerificationKey {
+	return hmacKey(secret)
+}
+
+func (k hmacKey) verify(content, signature []byte) bool {
+	return hmac.Equal(hmacSum(k, content), signature)
+}
+
+type ed25519Key ed25519.PublicKey
+
+// Ed25519VerificationKey is the key verifying the signatures of NewEd25519Signer with the public key.
+func Ed25519VerificationKey(key ed25519.PublicKey) VerificationKey {
+	return ed25519Key(key)
+}
+
+func (k ed25519Key) verify(content, signature []byte) bool {
+	return ed25519.Verify(ed25519.PublicKey(k), content, signature)
+}
+
+type verifier struct {
+	keys    map[string]VerificationKey
+	sources map[string][]string
+
+	// lock guards the ids of the verified write requests, keyed by the source and the id,
+	// and when they are out of the replay window.
+	lock sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewVerifier builds a Verifier accepting the events signed with one of the keys, the keys are
+// keyed by their ids. An event is accepted only if its source matches the sources of its key,
+// the sources are shell patterns keyed by the key ids, e.g. "syncer-*", so a key can not sign
+// for the sources of another key. A write request is accepted only once, and only if its time
+// is within a few minutes of now, so a captured write request can not be replayed. The
+// verified and the rejected events are counted in the expvar metrics
+// events_informer_signature_verified_total and events_informer_signature_rejected_total.
+func NewVerifier(keys map[string]VerificationKey, sources map[string][]string) Verifier {
+	return &verifier{keys: keys, sources: sources, seen: map[string]time.Time{}}
+}
+
+func (v *verifier) Verify(evt cloudevents.Event) error {
+	if err := v.verify(evt); err != nil {
+		rejectedEvents.Add(err.Reason, 1)
+		return err
+	}
+
+	verifiedEvents.Add(1)
+	return nil
+}
+
+func (v *verifier) verify(evt cloudevents.Event) *RejectedError {
+	keyID, encoded := apis.SignatureKey(evt), apis.Signature(evt)
+	if len(encoded) == 0 {
+		return &RejectedError{Reason: ReasonUnsigned}
+	}
+
+	key, ok := v.keys[keyID]
+	if !ok {
+		return &RejectedError{Reason: ReasonUnknownKey, KeyID: keyID}
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !key.verify(content(evt), signature) {
+		return &RejectedError{Reason: ReasonInvalid, KeyID: keyID}
+	}
+
+	if !matchesSource(v.sources[keyID], evt.Source()) {
+		return &RejectedError{Reason: ReasonUnauthorizedSource, KeyID: keyID}
+	}
+
+	if mode, _, err := apis.ParseEventType(evt.Type()); err == nil && apis.IsWriteMode(mode) {
+		return v.verifyOnce(evt, keyID)
+	}
+	return nil
+}
+
+// verifyOnce rejects the write request if its time is out of the replay window, or its id is
+// already verified.
+func (v *verifier) verifyOnce(evt cloudevents.Event, keyID string) *RejectedError {
+	now := time.Now()
+	if evt.Time().Before(now.Add(-replayWindow)) || evt.Time().After(now.Add(replayWindow)) {
+		return &RejectedError{Reason: ReasonExpired, KeyID: keyID}
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	for key, expiry := range v.seen {
+		if now.After(expiry) {
+			delete(v.seen, key)
+		}
+	}
+
+	key := evt.Source() + "/" + evt.ID()
+	if _, ok := v.seen[key]; ok {
+		return &RejectedError{Reason: ReasonReplayed, KeyID: keyID}
+	}
+	v.seen[key] = evt.Time().Add(replayWindow)
+	return nil
+}
+
+func matchesSource(patterns []string, source string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, source); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package signing
+
+import (
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+)
+
+func TestVerifierSources(t *testing.T) {
+	verifier := NewVerifier(
+		map[string]VerificationKey{
+			"syncer": HMACVerificationKey([]byte("syncer-secret")),
+			"admin":  HMACVerificationKey([]byte("admin-secret")),
+		},
+		map[string][]string{
+			"syncer": {"syncer-*"},
+			"admin":  {"admin"},
+		},
+	)
+
+	cases := []struct {
+		name   string
+		signer Signer
+		source string
+		reason string
+	}{
+		{
+			name:   "source of the key",
+			signer: NewHMACSigner("syncer", []byte("syncer-secret")),
+			source: "syncer-1",
+		},
+		{
+			name:   "source of another key",
+			signer: NewHMACSigner("syncer", []byte("syncer-secret")),
+			source: "admin",
+			reason: ReasonUnauthorizedSource,
+		},
+		{
+			name:   "invalid signature",
+			signer: NewHMACSigner("admin", []byte("syncer-secret")),
+			source: "admin",
+			reason: ReasonInvalid,
+		},
+		{
+			name:   "unknown key",
+			signer: NewHMACSigner("other", []byte("other-secret")),
+			source: "syncer-1",
+			reason: ReasonUnknownKey,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			evt := cloudevents.NewEvent()
+			evt.SetID("1")
+			evt.SetType("list//v1/secrets")
+			evt.SetSource(c.source)
+			if err := c.signer.Sign(&evt); err != nil {
+				t.Fatal(err)
+			}
+
+			err := verifier.Verify(evt)
+			if len(c.reason) == 0 {
+				if err != nil {
+					t.Errorf("expected the event is accepted, got %v", err)
+				}
+				return
+			}
+
+			rejected, ok := err.(*RejectedError)
+			if !ok || rejected.Reason != c.reason {
+				t.Errorf("expected the event is rejected as %s, got %v", c.reason, err)
+			}
+		})
+	}
+}
+
+func TestVerifierReplays(t *testing.T) {
+	verifier := NewVerifier(
+		map[string]VerificationKey{"syncer": HMACVerificationKey([]byte("syncer-secret"))},
+		map[string][]string{"syncer": {"syncer-*"}},
+	)
+	signer := NewHMACSigner("syncer", []byte("syncer-secret"))
+
+	newEvent := func(id, eventType string, eventTime time.Time) cloudevents.Event {
+		evt := cloudevents.NewEvent()
+		evt.SetID(id)
+		evt.SetType(eventType)
+		evt.SetSource("syncer-1")
+		if !eventTime.IsZero() {
+			evt.SetTime(eventTime)
+		}
+		if err := signer.Sign(&evt); err != nil {
+			t.Fatal(err)
+		}
+		return evt
+	}
+
+	expectReason := func(evt cloudevents.Event, reason string) {
+		t.Helper()
+
+		err := verifier.Verify(evt)
+		if len(reason) == 0 {
+			if err != nil {
+				t.Errorf("expected the event is accepted, got %v", err)
+			}
+			return
+		}
+		if rejected, ok := err.(*RejectedError); !ok || rejected.Reason != reason {
+			t.Errorf("expected the event is rejected as %s, got %v", reason, err)
+		}
+	}
+
+	create := newEvent("1", "create//v1/secrets", time.Time{})
+	if create.Time().IsZero() {
+		t.Errorf("expected the time of the event is set by the signer")
+	}
+	expectReason(create, "")
+	expectReason(create, ReasonReplayed)
+	// the same id from another source is another request.
+	other := newEvent("1", "create//v1/secrets", time.Time{})
+	other.SetSource("syncer-2")
+	if err := signer.Sign(&other); err != nil {
+		t.Fatal(err)
+	}
+	expectReason(other, "")
+
+	// the lists are idempotent, they may be delivered more than once.
+	list := newEvent("2", "list//v1/secrets", time.Now().Add(-time.Hour))
+	expectReason(list, "")
+	expectReason(list, "")
+
+	expectReason(newEvent("3", "delete//v1/secrets", time.Now().Add(-time.Hour)), ReasonExpired)
+	expectReason(newEvent("4", "patch//v1/secrets", time.Now().Add(time.Hour)), ReasonExpired)
+
+	// the time is signed, it can not be moved into the window.
+	expired := newEvent("5", "update//v1/secrets", time.Now().Add(-time.Hour))
+	expired.SetTime(time.Now())
+	expectReason(expired, ReasonInvalid)
+}
@@ -0,0 +1,28 @@
+package testing
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// SecretsGVR is the resource of the fixtures.
+var SecretsGVR = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+// NewSecret returns a secret with the namespace and the name.
+func NewSecret(namespace, name string) *unstructured.Unstructured {
+	secret := &unstructured.Unstructured{}
+	secret.SetAPIVersion("v1")
+	secret.SetKind("Secret")
+	secret.SetNamespace(namespace)
+	secret.SetName(name)
+	return secret
+}
+
+// NewFakeClient returns a fake dynamic client serving the secrets, the list kind of the
+// secrets is registered so the secrets can be listed.
+func NewFakeClient(objects ...runtime.Object) *fake.FakeDynamicClient {
+	return fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{SecretsGVR: "SecretList"}, objects...)
+}
@@ -0,0 +1,48 @@
+package testing
+
+import (
+	"context"
+	"time"
+
+	"github.com/qiujian16/events-informer/pkg/informers"
+	"github.com/qiujian16/events-informer/pkg/senders"
+	"github.com/qiujian16/events-informer/pkg/transport"
+	"github.com/qiujian16/events-informer/pkg/transport/loopback"
+	"k8s.io/client-go/dynamic"
+)
+
+// NewLoopbackInformerFactory wires an informer factory to a sender transport serving the
+// dynamic client, e.g. a fake dynamic client, over the loopback bus. The sender transport
+// runs and the informer factory receives until the context is done. Use the loopback.Options
+// of the bus to simulate latency, reordering and drops of the events.
+func NewLoopbackInformerFactory(
+	ctx context.Context,
+	bus *loopback.Bus,
+	client dynamic.Interface,
+	defaultResync time.Duration,
+	options ...informers.EventSharedInformerOption) (informers.EventSharedInformerFactory, error) {
+	if err := StartLoopbackSender(ctx, bus, client); err != nil {
+		return nil, err
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	return informers.NewEventSharedInformerFactoryWithOptions(ctx, sender, receiver, defaultResync, options...), nil
+}
+
+// StartLoopbackSender runs a sender transport serving the dynamic client over the loopback
+// bus until the context is done.
+func StartLoopbackSender(ctx context.Context, bus *loopback.Bus, client dynamic.Interface, options ...senders.SenderTransportOption) error {
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultResponseTopic, transport.DefaultRequestTopic)
+	if err != nil {
+		return err
+	}
+
+	senderTransport := senders.NewDefaultSenderTansport(senders.NewDynamicSender(client), sender, receiver, options...)
+	go senderTransport.Run(ctx)
+
+	return nil
+}
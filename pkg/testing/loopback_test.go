@@ -0,0 +1,188 @@
+package testing_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/qiujian16/events-informer/pkg/apis"
+	"github.com/qiujian16/events-informer/pkg/informers"
+	"github.com/qiujian16/events-informer/pkg/senders"
+	eitesting "github.com/qiujian16/events-informer/pkg/testing"
+	"github.com/qiujian16/events-informer/pkg/transport"
+	"github.com/qiujian16/events-informer/pkg/transport/loopback"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestLoopbackInformerFactory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := eitesting.NewFakeClient(eitesting.NewSecret("ns", "a"))
+
+	// the changes are made after the watch of the informer is started on the fake client.
+	watchStarted := make(chan struct{})
+	once := sync.Once{}
+	client.PrependWatchReactor("secrets", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		w, err := client.Tracker().Watch(eitesting.SecretsGVR, action.GetNamespace())
+		once.Do(func() { close(watchStarted) })
+		return true, w, err
+	})
+
+	factory, err := eitesting.NewLoopbackInformerFactory(ctx, loopback.NewBus(loopback.Options{}), client, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan string, 10)
+	informer := factory.ForResource(eitesting.SecretsGVR).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			events <- "add " + obj.(*unstructured.Unstructured).GetName()
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			events <- "update " + obj.(*unstructured.Unstructured).GetName()
+		},
+		DeleteFunc: func(obj interface{}) {
+			events <- "delete " + obj.(*unstructured.Unstructured).GetName()
+		},
+	})
+
+	factory.Start()
+	if synced := factory.WaitForCacheSync(ctx.Done()); !synced[eitesting.SecretsGVR] {
+		t.Fatalf("expected the informer of %s is synced", eitesting.SecretsGVR)
+	}
+	expectEvent(t, events, "add a")
+
+	<-watchStarted
+	secrets := client.Resource(eitesting.SecretsGVR).Namespace("ns")
+	if _, err := secrets.Create(ctx, eitesting.NewSecret("ns", "b"), metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, "add b")
+
+	if err := secrets.Delete(ctx, "b", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, "delete b")
+
+	secret := eitesting.NewSecret("ns", "a")
+	secret.SetLabels(map[string]string{"updated": "true"})
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, events, "update a")
+
+	obj, err := factory.ForResource(eitesting.SecretsGVR).Lister().ByNamespace("ns").Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels := obj.(*unstructured.Unstructured).GetLabels(); labels["updated"] != "true" {
+		t.Errorf("expected the updated secret in the lister, got labels %v", labels)
+	}
+}
+
+// TestLoopbackSenderStopped sends more requests than the loopback buffers after the sender is
+// stopped, the requests are not buffered for the stopped sender, so the sends do not block.
+func TestLoopbackSenderStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := loopback.NewBus(loopback.Options{})
+	senderCtx, stopSender := context.WithCancel(ctx)
+	if err := eitesting.StartLoopbackSender(senderCtx, bus, fake.NewSimpleDynamicClient(runtime.NewScheme())); err != nil {
+		t.Fatal(err)
+	}
+	stopSender()
+
+	sender, _, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < 2000; i++ {
+			evt := cloudevents.NewEvent()
+			evt.SetType(apis.EventListType(eitesting.SecretsGVR))
+			evt.SetSource("client")
+			sender.Send(ctx, evt)
+		}
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(10 * time.Second):
+		t.Fatal("sending to the stopped sender is blocked")
+	}
+}
+
+func expectEvent(t *testing.T, events <-chan string, expected string) {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		if event != expected {
+			t.Errorf("expected event %q, got %q", expected, event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for event %q", expected)
+	}
+}
+
+// TestClusterObjectWrites writes the objects of the multi-cluster informers, the writes are
+// sent to the cluster of the object, or refused by a client of another cluster.
+func TestClusterObjectWrites(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := loopback.NewBus(loopback.Options{})
+	clients := map[string]*fake.FakeDynamicClient{}
+	for _, cluster := range []string{"cluster1", "cluster2"} {
+		clients[cluster] = eitesting.NewFakeClient()
+		if err := eitesting.StartLoopbackSender(ctx, bus, clients[cluster], senders.WithClusterName(cluster)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sender, receiver, err := bus.NewClients(ctx, transport.DefaultRequestTopic, transport.DefaultResponseTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the secret as it is in the multi-cluster informers.
+	secret := eitesting.NewSecret(informers.ClusterNamespace("cluster2", "ns"), "a")
+	secret.SetAnnotations(map[string]string{informers.ClusterAnnotation: "cluster2"})
+
+	client := informers.NewEventDynamicClient(ctx, sender, receiver)
+	if _, err := client.Resource(eitesting.SecretsGVR).Namespace(secret.GetNamespace()).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := clients["cluster2"].Resource(eitesting.SecretsGVR).Namespace("ns").Get(ctx, "a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := created.GetAnnotations()[informers.ClusterAnnotation]; ok {
+		t.Errorf("expected the secret is created without the cluster annotation, got %v", created.GetAnnotations())
+	}
+	if _, err := clients["cluster1"].Resource(eitesting.SecretsGVR).Namespace("ns").Get(ctx, "a", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the secret is not created in cluster1, got %v", err)
+	}
+
+	cluster1Client := informers.NewEventDynamicClient(ctx, sender, receiver, informers.WithClusterName("cluster1"))
+	_, err = cluster1Client.Resource(eitesting.SecretsGVR).Namespace("ns").Update(ctx, secret, metav1.UpdateOptions{})
+	if !apierrors.IsBadRequest(err) {
+		t.Errorf("expected the secret of cluster2 is not written to cluster1, got %v", err)
+	}
+}
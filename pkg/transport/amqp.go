@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/binding/format"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// amqpInitialReconnectDelay and amqpMaxReconnectDelay bound the backoff of reconnecting
+	// to the broker when the link is lost.
+	amqpInitialReconnectDelay = time.Second
+	amqpMaxReconnectDelay     = 30 * time.Second
+)
+
+// amqpProtocol delivers the events over an amqp 1.0 broker in the structured content mode,
+// the payload of an amqp message is the json encoded cloud event. The connection and the
+// links are opened again when the link of the receiver is lost.
+type amqpProtocol struct {
+	url    string
+	opts   []amqp.ConnOption
+	topics Topics
+
+	// lock guards the connection and the links, they are replaced when reconnecting.
+	lock     sync.RWMutex
+	client   *amqp.Client
+	sender   *amqp.Sender
+	receiver *amqp.Receiver
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ protocol.Sender = &amqpProtocol{}
+var _ protocol.Receiver = &amqpProtocol{}
+
+func newAMQPProtocol(config *Config, topics Topics) (protocol.Sender, protocol.Receiver, func(ctx context.Context) error, error) {
+	if config.AMQP == nil || len(config.AMQP.URL) == 0 {
+		return nil, nil, nil, fmt.Errorf("amqp url is not configured")
+	}
+
+	p := &amqpProtocol{
+		url:    config.AMQP.URL,
+		topics: topics,
+		closed: make(chan struct{}),
+	}
+	if len(config.AMQP.Username) > 0 {
+		p.opts = append(p.opts, amqp.ConnSASLPlain(config.AMQP.Username, config.AMQP.Password))
+	}
+
+	if err := p.connect(); err != nil {
+		return nil, nil, nil, err
+	}
+	return p, p, p.Close, nil
+}
+
+// connect opens a connection and the links to the topics, and replaces the current ones.
+func (p *amqpProtocol) connect() error {
+	client, err := amqp.Dial(p.url, p.opts...)
+	if err != nil {
+		return err
+	}
+
+	sender, receiver, err := openAMQPLinks(client, p.topics)
+	if err != nil {
+		_ = client.Close()
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	select {
+	case <-p.closed:
+		return client.Close()
+	default:
+	}
+
+	if p.client != nil {
+		_ = p.client.Close()
+	}
+	p.client, p.sender, p.receiver = client, sender, receiver
+	return nil
+}
+
+func openAMQPLinks(client *amqp.Client, topics Topics) (*amqp.Sender, *amqp.Receiver, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sender, err := session.NewSender(amqp.LinkTargetAddress(topics.Send))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receiver, err := session.NewReceiver(amqp.LinkSourceAddress(topics.Receive))
+	if err != nil {
+		return nil, nil, err
+	}
+	return sender, receiver, nil
+}
+
+// reconnect connects to the broker again with backoff until it succeeds, it returns false if
+// the protocol is closed or the context is done first. The links are not replaced if they are
+// already replaced since the failed receiver.
+func (p *amqpProtocol) reconnect(ctx context.Context, failed *amqp.Receiver) bool {
+	delay := amqpInitialReconnectDelay
+	for {
+		p.lock.RLock()
+		replaced := p.receiver != failed
+		p.lock.RUnlock()
+		if replaced {
+			return true
+		}
+
+		err := p.connect()
+		if err == nil {
+			klog.Infof("reconnected to amqp broker %s", p.url)
+			return true
+		}
+		klog.Errorf("failed to reconnect to amqp broker %s, retry in %v: %v", p.url, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-p.closed:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+
+		delay *= 2
+		if delay > amqpMaxReconnectDelay {
+			delay = amqpMaxReconnectDelay
+		}
+	}
+}
+
+func (p *amqpProtocol) Send(ctx context.Context, m binding.Message, transformers ...binding.Transformer) error {
+	var err error
+	defer func() { _ = m.Finish(err) }()
+
+	evt, err := binding.ToEvent(ctx, m, transformers...)
+	if err != nil {
+		return err
+	}
+
+	payload, err := format.JSON.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	p.lock.RLock()
+	sender := p.sender
+	p.lock.RUnlock()
+
+	msg := amqp.NewMessage(payload)
+	msg.Properties = &amqp.MessageProperties{ContentType: event.ApplicationCloudEventsJSON}
+	err = sender.Send(ctx, msg)
+	return err
+}
+
+// Receive returns the next event of the receive address. The protocol reconnects to the
+// broker if the link is lost, the receiving ends only if the protocol is closed or the
+// context is done.
+func (p *amqpProtocol) Receive(ctx context.Context) (binding.Message, error) {
+	for {
+		p.lock.RLock()
+		receiver := p.receiver
+		p.lock.RUnlock()
+
+		msg, err := receiver.Receive(ctx)
+		if err != nil {
+			select {
+			case <-p.closed:
+				return nil, io.EOF
+			case <-ctx.Done():
+				return nil, io.EOF
+			default:
+			}
+
+			klog.Errorf("failed to receive from amqp broker, reconnecting: %v", err)
+			if !p.reconnect(ctx, receiver) {
+				return nil, io.EOF
+			}
+			continue
+		}
+
+		if err := msg.Accept(ctx); err != nil {
+			klog.Warningf("failed to accept amqp message: %v", err)
+		}
+
+		evt := event.New()
+		if err := format.JSON.Unmarshal(msg.GetData(), &evt); err != nil {
+			klog.Warningf("drop amqp message which is not a cloud event: %v", err)
+			continue
+		}
+		return binding.ToMessage(&evt), nil
+	}
+}
+
+func (p *amqpProtocol) Close(ctx context.Context) error {
+	var err error
+	p.closeOnce.Do(func() {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		close(p.closed)
+		err = p.client.Close()
+	})
+	return err
+}
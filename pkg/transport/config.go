@@ -0,0 +1,129 @@
+package transport
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ProtocolKafka = "kafka"
+	ProtocolMQTT  = "mqtt"
+	ProtocolNATS  = "nats"
+	ProtocolHTTP  = "http"
+	ProtocolAMQP  = "amqp"
+
+	DefaultRequestTopic  = "request-topic"
+	DefaultResponseTopic = "response-topic"
+	// DefaultRequestGroup is the consumer group of the senders receiving the requests. The
+	// senders of each cluster are in their own consumer group suffixed with the cluster name,
+	// so every request is received by a sender of each cluster.
+	DefaultRequestGroup = "request-group-id"
+	// DefaultResponseGroup is the prefix of the consumer group of an informer receiving the
+	// responses, the consumer group of each informer is suffixed with its client id.
+	DefaultResponseGroup = "response-group-id"
+)
+
+// Config is the configuration of the transport which the request and response events
+// are delivered by. Only the configuration of the selected protocol is used.
+type Config struct {
+	// Protocol is one of kafka, mqtt, nats, http, amqp or a protocol added by RegisterProtocol.
+	Protocol string `json:"protocol"`
+	// RequestTopic is the topic the informers send requests to, it defaults to request-topic.
+	RequestTopic string `json:"requestTopic,omitempty"`
+	// ResponseTopic is the topic the senders send responses to, it defaults to response-topic.
+	ResponseTopic string `json:"responseTopic,omitempty"`
+
+	Kafka *KafkaConfig `json:"kafka,omitempty"`
+	MQTT  *MQTTConfig  `json:"mqtt,omitempty"`
+	NATS  *NATSConfig  `json:"nats,omitempty"`
+	HTTP  *HTTPConfig  `json:"http,omitempty"`
+	AMQP  *AMQPConfig  `json:"amqp,omitempty"`
+}
+
+type KafkaConfig struct {
+	BootstrapServers []string `json:"bootstrapServers"`
+	// Version is the kafka version, it defaults to 2.0.0.
+	Version string `json:"version,omitempty"`
+	// GroupID is the consumer group of the receiver. It defaults to
+	// request-group-id-<cluster name> on the sender and response-group-id-<client id> on the informer.
+	GroupID string `json:"groupID,omitempty"`
+}
+
+// MQTTConfig configures the mqtt transport. The senders subscribe the request topic with the
+// shared subscription $share/<consumer group>/<request topic>, so the broker must support the
+// shared subscriptions to run more than one sender of a cluster.
+type MQTTConfig struct {
+	// BrokerHost is the address of the broker, e.g. tcp://127.0.0.1:1883.
+	BrokerHost string `json:"brokerHost"`
+	ClientID   string `json:"clientID,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	QoS        byte   `json:"qos,omitempty"`
+}
+
+type NATSConfig struct {
+	// URL is the address of the nats server, e.g. nats://127.0.0.1:4222.
+	URL string `json:"url"`
+	// QueueGroup is the queue group of the senders, the senders in the same queue group share
+	// the requests. It defaults to the consumer group of the senders. The informers never
+	// join a queue group, since every informer receives all the responses.
+	QueueGroup string `json:"queueGroup,omitempty"`
+}
+
+// HTTPConfig configures the point to point http transport. There is no broker, each side
+// listens on a port for the events and sends the events to its peer.
+type HTTPConfig struct {
+	Port   int    `json:"port"`
+	Target string `json:"target"`
+}
+
+// AMQPConfig configures the amqp 1.0 transport. The topics are the addresses of the broker,
+// the response topic must deliver each event to all its receivers, e.g. a multicast address,
+// since every informer receives the responses.
+type AMQPConfig struct {
+	// URL is the address of the broker, e.g. amqp://127.0.0.1:5672.
+	URL string `json:"url"`
+	// Username and Password authenticate with SASL PLAIN if the username is set.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// NewKafkaConfig builds the configuration of the kafka transport with the default topics.
+func NewKafkaConfig(bootstrapServers ...string) *Config {
+	return &Config{
+		Protocol: ProtocolKafka,
+		Kafka: &KafkaConfig{
+			BootstrapServers: bootstrapServers,
+		},
+	}
+}
+
+// LoadConfig reads the transport configuration from a yaml or json file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse transport config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+func (c *Config) requestTopic() string {
+	if len(c.RequestTopic) == 0 {
+		return DefaultRequestTopic
+	}
+	return c.RequestTopic
+}
+
+func (c *Config) responseTopic() string {
+	if len(c.ResponseTopic) == 0 {
+		return DefaultResponseTopic
+	}
+	return c.ResponseTopic
+}
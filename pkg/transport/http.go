@@ -0,0 +1,24 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	cehttp "github.com/cloudevents/sdk-go/v2/protocol/http"
+)
+
+// newHTTPProtocol builds the point to point http protocol, the topics are not used since
+// the events are sent to the target directly.
+func newHTTPProtocol(config *Config, _ Topics) (protocol.Sender, protocol.Receiver, func(ctx context.Context) error, error) {
+	if config.HTTP == nil || len(config.HTTP.Target) == 0 {
+		return nil, nil, nil, fmt.Errorf("http target is not configured")
+	}
+
+	p, err := cehttp.New(cehttp.WithPort(config.HTTP.Port), cehttp.WithTarget(config.HTTP.Target))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return p, p, nil, nil
+}
@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/cloudevents/sdk-go/protocol/kafka_sarama/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func newKafkaProtocol(config *Config, topics Topics) (protocol.Sender, protocol.Receiver, func(ctx context.Context) error, error) {
+	if config.Kafka == nil || len(config.Kafka.BootstrapServers) == 0 {
+		return nil, nil, nil, fmt.Errorf("kafka bootstrap servers are not configured")
+	}
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V2_0_0_0
+	if len(config.Kafka.Version) > 0 {
+		version, err := sarama.ParseKafkaVersion(config.Kafka.Version)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		saramaConfig.Version = version
+	}
+
+	group := topics.Group
+	if len(config.Kafka.GroupID) > 0 {
+		group = config.Kafka.GroupID
+	}
+
+	sender, err := kafka_sarama.NewSender(config.Kafka.BootstrapServers, saramaConfig, topics.Send)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	receiver, err := kafka_sarama.NewConsumer(config.Kafka.BootstrapServers, saramaConfig, group, topics.Receive)
+	if err != nil {
+		sender.Close(context.Background())
+		return nil, nil, nil, err
+	}
+
+	closer := func(ctx context.Context) error {
+		return utilerrors.NewAggregate([]error{sender.Close(ctx), receiver.Close(ctx)})
+	}
+
+	return sender, receiver, closer, nil
+}
@@ -0,0 +1,196 @@
+package loopback
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/event"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+)
+
+// subscriptionSize is the number of events buffered for a receiver.
+const subscriptionSize = 1024
+
+// Options simulates an unreliable transport on the Bus.
+type Options struct {
+	// Latency is the delay of delivering each event.
+	Latency time.Duration
+	// Jitter is the max random delay added to the latency. Events sent within the jitter
+	// might be delivered out of order.
+	Jitter time.Duration
+	// DropRate is the probability in [0, 1] that an event is dropped silently.
+	DropRate float64
+	// Seed is the seed of the random source deciding the jitters and drops.
+	Seed int64
+}
+
+// Bus delivers the cloud events between the protocols in the same process. An event sent
+// to a topic is delivered to all the protocols receiving from the topic. Without Options,
+// the events are delivered in order without loss.
+type Bus struct {
+	options Options
+
+	lock        sync.RWMutex
+	subscribers map[string]map[*Protocol]struct{}
+
+	randLock sync.Mutex
+	rand     *rand.Rand
+}
+
+func NewBus(options Options) *Bus {
+	return &Bus{
+		options:     options,
+		subscribers: map[string]map[*Protocol]struct{}{},
+		rand:        rand.New(rand.NewSource(options.Seed)),
+	}
+}
+
+// NewProtocol builds a protocol sending events to the sendTopic and receiving events from
+// the receiveTopic. It receives the events sent after it is built until it is closed.
+func (b *Bus) NewProtocol(sendTopic, receiveTopic string) *Protocol {
+	p := &Protocol{
+		bus:          b,
+		sendTopic:    sendTopic,
+		receiveTopic: receiveTopic,
+		incoming:     make(chan binding.Message, subscriptionSize),
+		closed:       make(chan struct{}),
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.subscribers[receiveTopic]; !ok {
+		b.subscribers[receiveTopic] = map[*Protocol]struct{}{}
+	}
+	b.subscribers[receiveTopic][p] = struct{}{}
+
+	return p
+}
+
+// NewClients builds the cloudevents clients sending events to the sendTopic and receiving
+// events from the receiveTopic. The protocol of the clients is closed when the context is
+// done, so the events sent to the receiveTopic are no longer buffered for the clients.
+func (b *Bus) NewClients(ctx context.Context, sendTopic, receiveTopic string) (sender, receiver cloudevents.Client, err error) {
+	p := b.NewProtocol(sendTopic, receiveTopic)
+	go func() {
+		<-ctx.Done()
+		p.Close(context.Background())
+	}()
+
+	sender, err = cloudevents.NewClient(p, cloudevents.WithTimeNow(), cloudevents.WithUUIDs())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receiver, err = cloudevents.NewClient(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sender, receiver, nil
+}
+
+func (b *Bus) unsubscribe(p *Protocol) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	delete(b.subscribers[p.receiveTopic], p)
+}
+
+// delay returns the delay of delivering an event, and false if the event is dropped.
+func (b *Bus) delay() (time.Duration, bool) {
+	b.randLock.Lock()
+	defer b.randLock.Unlock()
+
+	if b.options.DropRate > 0 && b.rand.Float64() < b.options.DropRate {
+		return 0, false
+	}
+
+	delay := b.options.Latency
+	if b.options.Jitter > 0 {
+		delay += time.Duration(b.rand.Int63n(int64(b.options.Jitter)))
+	}
+	return delay, true
+}
+
+func (b *Bus) publish(topic string, evt *event.Event) {
+	b.lock.RLock()
+	subscribers := make([]*Protocol, 0, len(b.subscribers[topic]))
+	for p := range b.subscribers[topic] {
+		subscribers = append(subscribers, p)
+	}
+	b.lock.RUnlock()
+
+	for _, p := range subscribers {
+		delay, ok := b.delay()
+		if !ok {
+			continue
+		}
+
+		// each receiver gets its own copy of the event
+		subscriber, copied := p, evt.Clone()
+		if delay == 0 {
+			subscriber.deliver(&copied)
+			continue
+		}
+		time.AfterFunc(delay, func() { subscriber.deliver(&copied) })
+	}
+}
+
+// Protocol is the cloudevents protocol of the Bus.
+type Protocol struct {
+	bus          *Bus
+	sendTopic    string
+	receiveTopic string
+
+	incoming  chan binding.Message
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ protocol.Sender = &Protocol{}
+var _ protocol.Receiver = &Protocol{}
+var _ protocol.Closer = &Protocol{}
+
+func (p *Protocol) Send(ctx context.Context, m binding.Message, transformers ...binding.Transformer) error {
+	evt, err := binding.ToEvent(ctx, m, transformers...)
+	_ = m.Finish(err)
+	if err != nil {
+		return err
+	}
+
+	p.bus.publish(p.sendTopic, evt)
+	return nil
+}
+
+func (p *Protocol) Receive(ctx context.Context) (binding.Message, error) {
+	select {
+	case m := <-p.incoming:
+		return m, nil
+	case <-p.closed:
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, io.EOF
+	}
+}
+
+// Close stops receiving events.
+func (p *Protocol) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		p.bus.unsubscribe(p)
+		close(p.closed)
+	})
+	return nil
+}
+
+func (p *Protocol) deliver(evt *event.Event) {
+	select {
+	case p.incoming <- binding.ToMessage(evt):
+	case <-p.closed:
+	}
+}
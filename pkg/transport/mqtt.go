@@ -0,0 +1,177 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/binding/format"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/klog/v2"
+)
+
+// mqttProtocol delivers the events over a mqtt broker in the structured content mode,
+// the payload of a mqtt message is the json encoded cloud event.
+type mqttProtocol struct {
+	client    mqtt.Client
+	qos       byte
+	sendTopic string
+	// subscription is the topic filter subscribed to receive, it is the shared subscription
+	// $share/<group>/<receive topic> on the senders, so a request is received by one sender of
+	// the group.
+	subscription string
+
+	incoming  chan binding.Message
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	// subscribed is true while the inbound is open, the receive topic is subscribed again
+	// whenever the client reconnects, since the subscriptions are lost with the clean session.
+	lock       sync.Mutex
+	subscribed bool
+}
+
+var _ protocol.Sender = &mqttProtocol{}
+var _ protocol.Receiver = &mqttProtocol{}
+var _ protocol.Opener = &mqttProtocol{}
+
+func newMQTTProtocol(config *Config, topics Topics) (protocol.Sender, protocol.Receiver, func(ctx context.Context) error, error) {
+	if config.MQTT == nil || len(config.MQTT.BrokerHost) == 0 {
+		return nil, nil, nil, fmt.Errorf("mqtt broker host is not configured")
+	}
+
+	clientID := config.MQTT.ClientID
+	if len(clientID) == 0 {
+		clientID = fmt.Sprintf("events-informer-%s", uuid.NewUUID())
+	}
+
+	p := &mqttProtocol{
+		qos:          config.MQTT.QoS,
+		sendTopic:    topics.Send,
+		subscription: mqttSubscription(topics),
+		incoming:     make(chan binding.Message),
+		closed:       make(chan struct{}),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.MQTT.BrokerHost).
+		SetClientID(clientID).
+		SetUsername(config.MQTT.Username).
+		SetPassword(config.MQTT.Password).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(p.onConnect)
+
+	p.client = mqtt.NewClient(opts)
+	token := p.client.Connect()
+	if token.Wait() && token.Error() != nil {
+		return nil, nil, nil, token.Error()
+	}
+
+	return p, p, p.Close, nil
+}
+
+// mqttSubscription returns the topic filter subscribed to receive from the topics. The shared
+// receivers subscribe the shared subscription of their group, the others the receive topic.
+func mqttSubscription(topics Topics) string {
+	if topics.Shared && len(topics.Group) > 0 {
+		return fmt.Sprintf("$share/%s/%s", topics.Group, topics.Receive)
+	}
+	return topics.Receive
+}
+
+func (p *mqttProtocol) Send(ctx context.Context, m binding.Message, transformers ...binding.Transformer) error {
+	var err error
+	defer func() { _ = m.Finish(err) }()
+
+	evt, err := binding.ToEvent(ctx, m, transformers...)
+	if err != nil {
+		return err
+	}
+
+	payload, err := format.JSON.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	token := p.client.Publish(p.sendTopic, p.qos, false, payload)
+	select {
+	case <-token.Done():
+		err = token.Error()
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return err
+}
+
+// OpenInbound subscribes the receive topic until the context is done.
+func (p *mqttProtocol) OpenInbound(ctx context.Context) error {
+	p.lock.Lock()
+	p.subscribed = true
+	p.lock.Unlock()
+
+	if token := p.subscribe(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+
+	<-ctx.Done()
+
+	p.lock.Lock()
+	p.subscribed = false
+	p.lock.Unlock()
+
+	p.client.Unsubscribe(p.subscription).Wait()
+	return nil
+}
+
+// onConnect subscribes the receive topic again when the client reconnects to the broker.
+func (p *mqttProtocol) onConnect(_ mqtt.Client) {
+	p.lock.Lock()
+	subscribed := p.subscribed
+	p.lock.Unlock()
+
+	if !subscribed {
+		return
+	}
+
+	if token := p.subscribe(); token.Wait() && token.Error() != nil {
+		klog.Errorf("failed to subscribe mqtt topic %s: %v", p.subscription, token.Error())
+	}
+}
+
+func (p *mqttProtocol) subscribe() mqtt.Token {
+	return p.client.Subscribe(p.subscription, p.qos, func(_ mqtt.Client, msg mqtt.Message) {
+		evt := cloudevents.NewEvent()
+		if err := format.JSON.Unmarshal(msg.Payload(), &evt); err != nil {
+			return
+		}
+
+		select {
+		case p.incoming <- binding.ToMessage(&evt):
+		case <-p.closed:
+		}
+	})
+}
+
+func (p *mqttProtocol) Receive(ctx context.Context) (binding.Message, error) {
+	select {
+	case m := <-p.incoming:
+		return m, nil
+	case <-p.closed:
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, io.EOF
+	}
+}
+
+func (p *mqttProtocol) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		p.client.Disconnect(250)
+	})
+	return nil
+}
@@ -0,0 +1,35 @@
+package transport
+
+import "testing"
+
+func TestMQTTSubscription(t *testing.T) {
+	cases := []struct {
+		name     string
+		topics   Topics
+		expected string
+	}{
+		{
+			name:     "sender",
+			topics:   Topics{Receive: "request-topic", Group: "request-group-id-cluster1", Shared: true},
+			expected: "$share/request-group-id-cluster1/request-topic",
+		},
+		{
+			name:     "informer",
+			topics:   Topics{Receive: "response-topic", Group: "response-group-id-client"},
+			expected: "response-topic",
+		},
+		{
+			name:     "shared without group",
+			topics:   Topics{Receive: "request-topic", Shared: true},
+			expected: "request-topic",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if subscription := mqttSubscription(c.topics); subscription != c.expected {
+				t.Errorf("expected subscription %q, got %q", c.expected, subscription)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+
+	cenats "github.com/cloudevents/sdk-go/protocol/nats/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	"github.com/nats-io/nats.go"
+)
+
+func newNATSProtocol(config *Config, topics Topics) (protocol.Sender, protocol.Receiver, func(ctx context.Context) error, error) {
+	if config.NATS == nil || len(config.NATS.URL) == 0 {
+		return nil, nil, nil, fmt.Errorf("nats url is not configured")
+	}
+
+	opts := []cenats.ProtocolOption{}
+	if topics.Shared {
+		queueGroup := topics.Group
+		if len(config.NATS.QueueGroup) > 0 {
+			queueGroup = config.NATS.QueueGroup
+		}
+		opts = append(opts, cenats.WithConsumerOptions(cenats.WithQueueSubscriber(queueGroup)))
+	}
+
+	p, err := cenats.NewProtocol(config.NATS.URL, topics.Send, topics.Receive, []nats.Option{}, opts...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return p, p, p.Close, nil
+}
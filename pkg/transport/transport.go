@@ -0,0 +1,129 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Topics are where a side of the transport sends events to and receives events from.
+type Topics struct {
+	Send    string
+	Receive string
+	// Group is the consumer group of the receiver, the receivers in the same group share the events.
+	Group string
+	// Shared is true on the sender side, the requests are shared by the senders in the group.
+	// On the informer side, every informer receives all the responses.
+	Shared bool
+}
+
+// ProtocolBuilder builds the protocols sending events to and receiving events from the topics.
+// The closer is called to release the connections when the clients are closed.
+type ProtocolBuilder func(config *Config, topics Topics) (sender protocol.Sender, receiver protocol.Receiver, closer func(ctx context.Context) error, err error)
+
+var (
+	buildersLock sync.RWMutex
+	builders     = map[string]ProtocolBuilder{
+		ProtocolKafka: newKafkaProtocol,
+		ProtocolMQTT:  newMQTTProtocol,
+		ProtocolNATS:  newNATSProtocol,
+		ProtocolHTTP:  newHTTPProtocol,
+		ProtocolAMQP:  newAMQPProtocol,
+	}
+)
+
+// RegisterProtocol adds a protocol which can be selected by the protocol of the Config,
+// e.g. a protocol of another broker. A registered protocol replaces the builtin one with the same name.
+func RegisterProtocol(name string, builder ProtocolBuilder) {
+	buildersLock.Lock()
+	defer buildersLock.Unlock()
+
+	builders[name] = builder
+}
+
+// Clients are the cloudevents clients of a side of the transport.
+type Clients struct {
+	Sender   cloudevents.Client
+	Receiver cloudevents.Client
+	closer   func(ctx context.Context) error
+}
+
+// Close releases the connections of the clients.
+func (c *Clients) Close(ctx context.Context) error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer(ctx)
+}
+
+// NewSenderClients builds the clients of the sender side of the cluster, which receives the
+// requests and sends the responses. They are used to build the senders.SenderTransport with
+// the same cluster name. The requests to all the clusters are delivered to every cluster, so
+// the senders of each cluster are in their own consumer group.
+func NewSenderClients(config *Config, clusterName string) (*Clients, error) {
+	group := DefaultRequestGroup
+	if len(clusterName) > 0 {
+		group = fmt.Sprintf("%s-%s", DefaultRequestGroup, clusterName)
+	}
+
+	return newClients(config, Topics{
+		Send:    config.responseTopic(),
+		Receive: config.requestTopic(),
+		Group:   group,
+		Shared:  true,
+	})
+}
+
+// NewInformerClients builds the clients of the informer side, which sends the requests and
+// receives the responses. They are used to build the informers.EventSharedInformerFactory
+// with the same client id. The responses to all the clients are delivered to every client,
+// so the receiver of each client is in its own consumer group.
+func NewInformerClients(config *Config, clientID string) (*Clients, error) {
+	return newClients(config, Topics{
+		Send:    config.requestTopic(),
+		Receive: config.responseTopic(),
+		Group:   fmt.Sprintf("%s-%s", DefaultResponseGroup, clientID),
+	})
+}
+
+func newClients(config *Config, topics Topics) (*Clients, error) {
+	buildersLock.RLock()
+	builder, ok := builders[config.Protocol]
+	buildersLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported transport protocol %q", config.Protocol)
+	}
+
+	sender, receiver, closer, err := builder(config, topics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s protocol: %v", config.Protocol, err)
+	}
+
+	sc, err := cloudevents.NewClient(sender, cloudevents.WithTimeNow(), cloudevents.WithUUIDs())
+	if err != nil {
+		return nil, utilerrors.NewAggregate([]error{fmt.Errorf("failed to create client, %v", err), closeProtocol(closer)})
+	}
+
+	rc, err := cloudevents.NewClient(receiver)
+	if err != nil {
+		return nil, utilerrors.NewAggregate([]error{fmt.Errorf("failed to create client, %v", err), closeProtocol(closer)})
+	}
+
+	return &Clients{
+		Sender:   sc,
+		Receiver: rc,
+		closer:   closer,
+	}, nil
+}
+
+func closeProtocol(closer func(ctx context.Context) error) error {
+	if closer == nil {
+		return nil
+	}
+	return closer(context.Background())
+}
@@ -0,0 +1,155 @@
+package transport
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/protocol"
+)
+
+// fakeProtocol is a protocol recording the topics it is built with.
+type fakeProtocol struct {
+	topics Topics
+	closed bool
+}
+
+func (p *fakeProtocol) Send(ctx context.Context, m binding.Message, transformers ...binding.Transformer) error {
+	return nil
+}
+
+func (p *fakeProtocol) Receive(ctx context.Context) (binding.Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *fakeProtocol) Close(ctx context.Context) error {
+	p.closed = true
+	return nil
+}
+
+func registerFakeProtocol(t *testing.T, name string) *[]*fakeProtocol {
+	built := &[]*fakeProtocol{}
+	RegisterProtocol(name, func(config *Config, topics Topics) (protocol.Sender, protocol.Receiver, func(ctx context.Context) error, error) {
+		p := &fakeProtocol{topics: topics}
+		*built = append(*built, p)
+		return p, p, p.Close, nil
+	})
+	t.Cleanup(func() {
+		buildersLock.Lock()
+		defer buildersLock.Unlock()
+		delete(builders, name)
+	})
+	return built
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.yaml")
+	if err := ioutil.WriteFile(valid, []byte(`
+protocol: mqtt
+requestTopic: requests
+mqtt:
+  brokerHost: tcp://127.0.0.1:1883
+  qos: 1
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Protocol != ProtocolMQTT || config.MQTT == nil || config.MQTT.BrokerHost != "tcp://127.0.0.1:1883" || config.MQTT.QoS != 1 {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if config.requestTopic() != "requests" || config.responseTopic() != DefaultResponseTopic {
+		t.Errorf("unexpected topics %q and %q", config.requestTopic(), config.responseTopic())
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := ioutil.WriteFile(invalid, []byte("protocol: [mqtt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(invalid); err == nil || !strings.Contains(err.Error(), "failed to parse transport config") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("expected error of a missing file")
+	}
+}
+
+func TestRegisterProtocol(t *testing.T) {
+	built := registerFakeProtocol(t, "fake")
+	config := &Config{Protocol: "fake"}
+
+	sender, err := NewSenderClients(config, "cluster1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	informer, err := NewInformerClients(config, "client1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*built) != 2 {
+		t.Fatalf("expected 2 protocols are built, got %d", len(*built))
+	}
+
+	expected := []Topics{
+		{Send: DefaultResponseTopic, Receive: DefaultRequestTopic, Group: "request-group-id-cluster1", Shared: true},
+		{Send: DefaultRequestTopic, Receive: DefaultResponseTopic, Group: "response-group-id-client1"},
+	}
+	for i, p := range *built {
+		if p.topics != expected[i] {
+			t.Errorf("expected topics %+v, got %+v", expected[i], p.topics)
+		}
+	}
+
+	if err := sender.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := informer.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range *built {
+		if !p.closed {
+			t.Errorf("expected the protocol is closed")
+		}
+	}
+}
+
+func TestUnsupportedProtocol(t *testing.T) {
+	if _, err := NewSenderClients(&Config{Protocol: "unknown"}, ""); err == nil || !strings.Contains(err.Error(), "unsupported transport protocol") {
+		t.Errorf("expected unsupported protocol error, got %v", err)
+	}
+}
+
+func TestUnconfiguredProtocols(t *testing.T) {
+	for _, name := range []string{ProtocolKafka, ProtocolMQTT, ProtocolNATS, ProtocolAMQP} {
+		if _, err := NewInformerClients(&Config{Protocol: name}, "client1"); err == nil {
+			t.Errorf("expected error of the unconfigured %s protocol", name)
+		}
+	}
+}
+
+func TestAMQPReconnectStops(t *testing.T) {
+	// nothing listens on the port, so the reconnecting keeps failing until it is stopped.
+	p := &amqpProtocol{url: "amqp://127.0.0.1:1", closed: make(chan struct{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if p.reconnect(ctx, nil) {
+		t.Errorf("expected the reconnecting stops when the context is done")
+	}
+
+	close(p.closed)
+	if p.reconnect(context.Background(), nil) {
+		t.Errorf("expected the reconnecting stops when the protocol is closed")
+	}
+}